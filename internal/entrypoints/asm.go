@@ -6,11 +6,16 @@ import (
 	"log/slog"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/robalb/tinyasm/pkg/configfiles"
 	"github.com/robalb/tinyasm/pkg/datafiles"
 	"github.com/robalb/tinyasm/pkg/envconfig"
+	"github.com/robalb/tinyasm/pkg/gitlab"
+	"github.com/robalb/tinyasm/pkg/issues"
 	"github.com/robalb/tinyasm/pkg/pipeline"
+	"github.com/robalb/tinyasm/pkg/report"
+	"github.com/robalb/tinyasm/pkg/surfacediff"
 )
 
 func Asm(
@@ -44,6 +49,18 @@ func Asm(
 	logger.Info("Config folder", "path", envConfig.ConfigFolder)
 	logger.Info("Data folder", "path", envConfig.DataFolder)
 
+	// The GitLab issues are optional, but a wrong configuration is reported
+	// before the discovery starts
+	var notifier *gitlab.Notifier
+	if envConfig.GitlabProject != "" || envConfig.GitlabToken != "" {
+		notifier, err = gitlab.New(logger, envConfig.GitlabURL, envConfig.GitlabProject, envConfig.GitlabToken, envConfig.GitlabIssues)
+		if err != nil {
+			logger.Error("Invalid GitLab configuration", "error", err)
+			return err
+		}
+		logger.Info("GitLab issues enabled", "project", envConfig.GitlabProject, "mode", envConfig.GitlabIssues)
+	}
+
 	// Read all the configuration files, based on the path set in the ENV variables
 	configFiles, err := configfiles.New(envConfig.ConfigFolder)
 	if err != nil {
@@ -64,13 +81,101 @@ func Asm(
 			"suggestion", "If this is not the fist execution, make sure the data folder is being saved properly.")
 	}
 
-	pipeline.RunSurfaceDiscovery(
+	// The dns cache of the previous runs is optional: when it can't be read,
+	// the run starts with an empty one
+	dnsCache := pipeline.NewDNSCache()
+	if configFiles.Config.DNS.Cache {
+		entries, err := dataFiles.LoadDNSCache()
+		if err != nil {
+			logger.Warn("Failed to load the dns cache, starting with an empty one", "error", err)
+		}
+		dnsCache.Load(entries)
+		logger.Info("dns cache loaded", "entries", len(entries))
+	}
+
+	discovery, err := pipeline.RunSurfaceDiscovery(
 		ctx,
 		logger,
+		dnsCache,
 		&dataFiles.KnownSurface,
+		dataFiles.KnownAssets,
 		&configFiles.Scope,
 		&configFiles.Exclusions,
+		&configFiles.Config,
+		configFiles.Pipeline,
 	)
+	if err != nil {
+		logger.Error("Surface discovery failed", "error", err)
+		return err
+	}
+
+	if len(discovery.ExcludedByIP) > 0 {
+		logger.Warn("Some discovered domains were excluded, because they resolve into excluded IPs",
+			"domains", discovery.ExcludedByIP)
+	}
+
+	// Compare what was seen in this run with what was seen in the previous one
+	surfaceDiff := surfacediff.Diff(dataFiles.LastSurface, discovery.Current)
+	surfaceDiff.HTTP = surfacediff.DiffHTTP(dataFiles.KnownHTTP, discovery.HTTP)
+	surfaceDiff.Wildcards = discovery.Wildcards
+	logger.Info("Surface changes", "summary", surfaceDiff.Summary())
+
+	// Compare the issues detected in this run with the ones of the previous run,
+	// setting aside the ones the user marked as false positives
+	now := time.Now()
+	issueReport := issues.Classify(dataFiles.KnownIssues, discovery.Issues, configFiles.IgnoreIssues, now)
+	logger.Info("Issues", "summary", issueReport.Summary())
+
+	// The overview is printed for the CI job log, and saved next to the data files
+	// so that reviewers can read it in the merge request
+	overview := report.Overview{Surface: surfaceDiff, Issues: issueReport, Time: now}
+	markdown := overview.Markdown()
+	_, err = stdout.Write(markdown)
+	if err != nil {
+		logger.Error("Failed to write the overview", "error", err)
+		return err
+	}
+	yamlOverview, err := overview.YAML()
+	if err != nil {
+		logger.Error("Failed to serialize the overview", "error", err)
+		return err
+	}
+	err = dataFiles.SaveOverview(markdown, yamlOverview)
+	if err != nil {
+		logger.Error("Failed to save the overview", "error", err)
+		return err
+	}
+
+	// Persist the merged surface, so that it can be committed by the CI job
+	// and used as the known surface of the next run
+	err = dataFiles.SaveKnownSurface(discovery)
+	if err != nil {
+		logger.Error("Failed to save the discovered surface", "error", err)
+		return err
+	}
+
+	err = dataFiles.SaveKnownIssues(issueReport.Active())
+	if err != nil {
+		logger.Error("Failed to save the discovered issues", "error", err)
+		return err
+	}
+	logger.Info("Discovered surface saved", "summary", dataFiles.Summary())
+
+	// The data files are already saved: a GitLab failure must not lose the run,
+	// the findings it could not report are opened by the next one
+	if notifier != nil {
+		err = notifier.Notify(ctx, issueReport)
+		if err != nil {
+			logger.Warn("Failed to update the GitLab issues", "error", err)
+		}
+	}
+
+	if configFiles.Config.DNS.Cache {
+		err = dataFiles.SaveDNSCache(dnsCache.Entries())
+		if err != nil {
+			logger.Warn("Failed to save the dns cache", "error", err)
+		}
+	}
 
 	return nil
 }
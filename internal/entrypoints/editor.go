@@ -0,0 +1,96 @@
+package entrypoints
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/configfiles"
+	"github.com/robalb/tinyasm/pkg/datafiles"
+	"github.com/robalb/tinyasm/pkg/editor"
+	"github.com/robalb/tinyasm/pkg/envconfig"
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func Editor(
+	ctx context.Context,
+	stdout io.Writer,
+	stderr io.Writer,
+	args []string,
+	getenv func(string) string,
+) error {
+	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(stdout, nil))
+	logger.Info("Starting the TinyASM pipeline editor")
+
+	envConfig, err := envconfig.New(args, getenv, logger)
+	if err != nil {
+		logger.Error("Failed to parse all the environment variables", "error", err)
+		return err
+	}
+	logger.Info("Config folder", "path", envConfig.ConfigFolder)
+	logger.Info("Web folder", "path", envConfig.WebFolder)
+
+	// Pipelines are validated against the same configuration asm would run them with
+	configFiles, err := configfiles.New(envConfig.ConfigFolder)
+	if err != nil {
+		logger.Error("Failed to parse all the configuration files", "error", err)
+		return err
+	}
+	logger.Info("file configuration values", "summary", configFiles.Summary())
+
+	pipelinePath := path.Join(envConfig.ConfigFolder, configfiles.PipelineFileName)
+	editorServer := editor.New(logger, &configFiles.Config, pipelinePath, envConfig.WebFolder, envConfig.EditorAddress)
+
+	// In debug mode, pipelines run against the scope and the known surface,
+	// but nothing they discover is written back into the data folder
+	if envConfig.EditorDebug == "true" {
+		dataFiles, _, err := datafiles.New(envConfig.DataFolder)
+		if err != nil {
+			logger.Error("Failed to access or parse the data folder content", "error", err)
+			return err
+		}
+		dnsCache := pipeline.NewDNSCache()
+		editorServer.EnableDebug(func(logger *slog.Logger) *pipeline.Env {
+			return pipeline.NewEnv(
+				logger,
+				dnsCache,
+				&dataFiles.KnownSurface,
+				dataFiles.KnownAssets,
+				&configFiles.Scope,
+				&configFiles.Exclusions,
+				&configFiles.Config,
+			)
+		})
+		logger.Warn("Debug mode enabled: pipelines run from the editor start actual scans")
+	}
+
+	server := &http.Server{
+		Addr:              envConfig.EditorAddress,
+		Handler:           editorServer.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Editor listening", "address", "http://"+envConfig.EditorAddress)
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Editor server failed", "error", err)
+		return err
+	}
+	return nil
+}
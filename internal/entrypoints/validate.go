@@ -29,12 +29,12 @@ func Validate(
 	logger.Info("Data folder", "path", envConfig.DataFolder)
 
 	// Read all the configuration files, based on the path set in the ENV variables
-	_, err = configfiles.New(envConfig.ConfigFolder)
+	configFiles, err := configfiles.New(envConfig.ConfigFolder)
 	if err != nil {
 		logger.Error("Failed to parse all the configuration files", "error", err)
 		return err
 	}
-	logger.Info("Configuration files: OK")
+	logger.Info("Configuration files: OK", "summary", configFiles.Summary())
 
 	// Read all the data files, based on the path set in the ENV variables
 	_, fileMissing, err := datafiles.New(envConfig.DataFolder)
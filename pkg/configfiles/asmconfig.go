@@ -0,0 +1,41 @@
+package configfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// parseAsmconfig reads the pipeline tuning parameters.
+// The asmconfig file is optional: every parameter that is not set,
+// including the ones of a missing file, keeps its default value.
+func parseAsmconfig(filePath string) (*pipeline.Config, error) {
+	config := pipeline.DefaultConfig()
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read asmconfig file at %s: %w", filePath, err)
+	}
+
+	// Unknown fields are rejected, so that a typo in a parameter name
+	// does not silently fall back to the default value
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Failed to parse asmconfig file at %s: Invalid Syntax: %w", filePath, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Failed to parse asmconfig file at %s: %w", filePath, err)
+	}
+
+	return &config, nil
+}
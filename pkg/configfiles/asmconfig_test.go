@@ -0,0 +1,103 @@
+package configfiles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func TestBadAsmconfigFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		filePath    string
+		errContains string
+	}{
+		{"unknown_field", "testdata/asmconfig/unknown_field.yaml", "field thread not found"},
+		{"out_of_range", "testdata/asmconfig/out_of_range.yaml", "'subfinder.threads' must be between"},
+		{"malformed_yaml", "testdata/asmconfig/malformed_yaml.yaml", "Invalid Syntax"},
+		{"invalid_resolver", "testdata/asmconfig/invalid_resolver.yaml", "'dns.trusted_resolvers' has an invalid resolver at index 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAsmconfig(tt.filePath)
+			if err == nil {
+				t.Fatalf("Expected error for %s, got nil", tt.name)
+			}
+			if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+				t.Fatalf("Error message doesn't contain %q: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestValidAsmconfigFiles(t *testing.T) {
+	partial := pipeline.DefaultConfig()
+	partial.Httpx.Threads = 20
+	// the example config spells out the default values
+	example := pipeline.DefaultConfig()
+	example.DNS.Resolvers = []string{}
+	example.DNS.TrustedResolvers = []string{}
+
+	tests := []struct {
+		name     string
+		filePath string
+		expected pipeline.Config
+	}{
+		{
+			name:     "full",
+			filePath: "testdata/asmconfig/valid_full.yaml",
+			expected: pipeline.Config{
+				Subfinder: pipeline.SubfinderConfig{Threads: 10, Timeout: 20, MaxEnumerationTime: 5},
+				Alterx:    pipeline.AlterxConfig{MaxSize: 500, Enrich: false},
+				Httpx:     pipeline.HttpxConfig{Threads: 50, Timeout: 5, Retries: 2, RateLimit: 100},
+				DNS: pipeline.DNSConfig{
+					Threads:          100,
+					RateLimit:        500,
+					Retries:          1,
+					Timeout:          2,
+					Resolvers:        []string{"10.0.0.53", "10.0.0.54:5353"},
+					TrustedResolvers: []string{"1.1.1.1"},
+					Cache:            true,
+					NegativeTTL:      600,
+					WildcardProbes:   5,
+					WildcardDepth:    1,
+				},
+			},
+		},
+		{
+			name:     "partial",
+			filePath: "testdata/asmconfig/valid_partial.yaml",
+			expected: partial,
+		},
+		{
+			name:     "empty_file",
+			filePath: "testdata/asmconfig/empty_file.yaml",
+			expected: pipeline.DefaultConfig(),
+		},
+		{
+			name:     "example",
+			filePath: "../../test/config/asmconfig.yaml",
+			expected: example,
+		},
+		{
+			name:     "missing_file",
+			filePath: "testdata/asmconfig/DO_NOT_CREATE_ME",
+			expected: pipeline.DefaultConfig(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseAsmconfig(tt.filePath)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(*config, tt.expected) {
+				t.Errorf("Config mismatch.\nExpected: %+v\nGot: %+v", tt.expected, *config)
+			}
+		})
+	}
+}
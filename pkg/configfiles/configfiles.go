@@ -12,13 +12,18 @@ var (
 	ScopeFileName     = "scope.yaml"
 	ignoreFileName    = "ignore-issues.yaml"
 	asmconfigFileName = "asmconfig.yaml"
+	PipelineFileName  = "pipeline.yaml"
 )
 
 type ConfigFiles struct {
 	Scope      pipeline.Surface
 	Exclusions pipeline.Surface
-	//IgnoreIssues IgnoreIssues //TODO
-	//Config Config //todo
+	// IgnoreIssues is the list of identifiers of the issues to suppress
+	IgnoreIssues []string
+	// Config holds the tuning parameters of the pipeline stages
+	Config pipeline.Config
+	// Pipeline is the definition of the surface discovery pipeline
+	Pipeline *pipeline.Definition
 }
 
 func New(configFolder string) (*ConfigFiles, error) {
@@ -28,25 +33,53 @@ func New(configFolder string) (*ConfigFiles, error) {
 		return nil, err
 	}
 
+	ignoreFilePath := path.Join(configFolder, ignoreFileName)
+	ignoreFileData, err := parseIgnoreIssues(ignoreFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	asmconfigFilePath := path.Join(configFolder, asmconfigFileName)
+	asmconfigData, err := parseAsmconfig(asmconfigFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	pipelineFilePath := path.Join(configFolder, PipelineFileName)
+	pipelineData, err := parsePipeline(pipelineFilePath, asmconfigData)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ConfigFiles{
 			scopeFileData.Scope,
 			scopeFileData.Exclusions,
+			ignoreFileData.Ignore,
+			*asmconfigData,
+			pipelineData,
 		},
 		nil
 }
 
 func (c *ConfigFiles) Summary() string {
 	scope := fmt.Sprintf(
-		"Elements in scope: {Domains[%d], IPs[%d], Endpoints[%d]}",
+		"Elements in scope: {Domains[%d], IPs[%d], Endpoints[%d], Services[%d], Ports%v, Wildcards%v}",
 		len(c.Scope.Domains),
 		len(c.Scope.IPs),
 		len(c.Scope.URLs),
+		len(c.Scope.Services),
+		c.Scope.Ports,
+		c.Scope.Wildcards,
 	)
 	exclusions := fmt.Sprintf(
-		"Elements excluded from scope: {Domains[%d], IPs[%d], Endpoints[%d]}",
-		len(c.Scope.Domains),
-		len(c.Scope.IPs),
-		len(c.Scope.URLs),
+		"Elements excluded from scope: {Domains[%d], IPs[%d], Endpoints[%d], Services[%d]}",
+		len(c.Exclusions.Domains),
+		len(c.Exclusions.IPs),
+		len(c.Exclusions.URLs),
+		len(c.Exclusions.Services),
 	)
-	return fmt.Sprintf("%s, %s ", scope, exclusions)
+	ignored := fmt.Sprintf("Ignored issues: %d", len(c.IgnoreIssues))
+	config := fmt.Sprintf("Pipeline config: %+v", c.Config)
+	pipelineSummary := fmt.Sprintf("Pipeline: %s, %d nodes", c.Pipeline.Name, len(c.Pipeline.Nodes))
+	return fmt.Sprintf("%s, %s, %s, %s, %s ", scope, exclusions, ignored, config, pipelineSummary)
 }
@@ -0,0 +1,44 @@
+package configfiles
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robalb/tinyasm/pkg/validation"
+	"gopkg.in/yaml.v3"
+)
+
+type ignoreFileData struct {
+	Ignore []string `yaml:"ignore"`
+}
+
+// parseIgnoreIssues reads the list of issue identifiers to suppress.
+// The ignore file is optional: a missing file is an empty list.
+func parseIgnoreIssues(filePath string) (*ignoreFileData, error) {
+	config := ignoreFileData{
+		Ignore: []string{},
+	}
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read ignore file at %s: %w", filePath, err)
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Failed to parse ignore file at %s: Invalid Syntax: %w", filePath, err)
+	}
+	if config.Ignore == nil {
+		config.Ignore = []string{}
+	}
+
+	for i, id := range config.Ignore {
+		if err := validation.ValidateIssueID(id); err != nil {
+			return nil, fmt.Errorf("Failed to parse ignore file at %s: Invalid issue at index %d: %w", filePath, i, err)
+		}
+	}
+
+	return &config, nil
+}
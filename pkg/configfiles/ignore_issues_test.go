@@ -0,0 +1,70 @@
+package configfiles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBadIgnoreFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		filePath    string
+		errContains string
+	}{
+		{"empty_id", "testdata/ignore/empty_id.yaml", "cannot be empty"},
+		{"invalid_id", "testdata/ignore/invalid_id.yaml", "invalid format"},
+		{"malformed_yaml", "testdata/ignore/malformed_yaml.yaml", "Invalid Syntax"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseIgnoreIssues(tt.filePath)
+			if err == nil {
+				t.Fatalf("Expected error for %s, got nil", tt.name)
+			}
+			if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+				t.Fatalf("Error message doesn't contain %q: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestValidIgnoreFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		filePath string
+		expected []string
+	}{
+		{
+			name:     "basic",
+			filePath: "testdata/ignore/valid_basic.yaml",
+			expected: []string{
+				"takeover:aws-s3:assets.example.com",
+				"new-technology:jenkins:https://ci.example.com",
+			},
+		},
+		{
+			name:     "empty_file",
+			filePath: "testdata/ignore/empty_file.yaml",
+			expected: []string{},
+		},
+		{
+			name:     "missing_file",
+			filePath: "testdata/ignore/DO_NOT_CREATE_ME",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseIgnoreIssues(tt.filePath)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(config.Ignore, tt.expected) {
+				t.Errorf("Ignore mismatch.\nExpected: %v\nGot: %v", tt.expected, config.Ignore)
+			}
+		})
+	}
+}
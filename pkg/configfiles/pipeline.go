@@ -0,0 +1,33 @@
+package configfiles
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+// parsePipeline reads the surface discovery pipeline definition, in yaml or json.
+// The pipeline file is optional: when it is missing, the built-in pipeline is used.
+// The definition is compiled against config, so that a broken pipeline
+// is reported at startup instead of halfway through a run.
+func parsePipeline(filePath string, config *pipeline.Config) (*pipeline.Definition, error) {
+	definition := pipeline.DefaultDefinition()
+
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read pipeline file at %s: %w", filePath, err)
+	}
+	if err == nil {
+		definition, err = pipeline.ParseDefinition(data)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse pipeline file at %s: %w", filePath, err)
+		}
+	}
+
+	if _, err := pipeline.CompileSurfaceDiscovery(definition, config); err != nil {
+		return nil, fmt.Errorf("Failed to parse pipeline file at %s: %w", filePath, err)
+	}
+
+	return definition, nil
+}
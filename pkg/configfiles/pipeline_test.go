@@ -0,0 +1,63 @@
+package configfiles
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func TestBadPipelineFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		filePath    string
+		errContains string
+	}{
+		{"malformed_yaml", "testdata/pipeline/malformed_yaml.yaml", "Invalid Syntax"},
+		{"type_mismatch", "testdata/pipeline/type_mismatch.yaml", "cannot connect an output of type surface to an input of type domains"},
+		{"no_discovery", "testdata/pipeline/no_discovery.yaml", "exactly one discovery node"},
+		{"unknown_operator", "testdata/pipeline/unknown_operator.yaml", "unknown operator 'nmap'"},
+	}
+
+	config := pipeline.DefaultConfig()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePipeline(tt.filePath, &config)
+			if err == nil {
+				t.Fatalf("Expected error for %s, got nil", tt.name)
+			}
+			if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+				t.Fatalf("Error message doesn't contain %q: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestValidPipelineFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		filePath      string
+		expectedName  string
+		expectedNodes int
+	}{
+		{"minimal", "testdata/pipeline/valid_minimal.yaml", "http-only", 4},
+		{"json", "testdata/pipeline/valid_json.yaml", "scope-only", 2},
+		{"missing_file", "testdata/pipeline/DO_NOT_CREATE_ME", "default", len(pipeline.DefaultDefinition().Nodes)},
+	}
+
+	config := pipeline.DefaultConfig()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition, err := parsePipeline(tt.filePath, &config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if definition.Name != tt.expectedName {
+				t.Errorf("Expected pipeline %q, got %q", tt.expectedName, definition.Name)
+			}
+			if len(definition.Nodes) != tt.expectedNodes {
+				t.Errorf("Expected %d nodes, got %d", tt.expectedNodes, len(definition.Nodes))
+			}
+		})
+	}
+}
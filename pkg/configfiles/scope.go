@@ -6,6 +6,7 @@ import (
 	"github.com/robalb/tinyasm/pkg/validation"
 	"gopkg.in/yaml.v3"
 	"os"
+	"slices"
 )
 
 type scopeFileData struct {
@@ -21,14 +22,21 @@ func parseScope(filePath string) (*scopeFileData, error) {
 
 	config := scopeFileData{
 		Scope: pipeline.Surface{
-			Domains: []string{},
-			IPs:     []string{},
-			URLs:    []string{},
+			Domains:    []string{},
+			IPs:        []string{},
+			URLs:       []string{},
+			Services:   []string{},
+			Ports:      []int{},
+			Wildcards:  []string{},
+			AssetPorts: map[string][]int{},
 		},
 		Exclusions: pipeline.Surface{
-			Domains: []string{},
-			IPs:     []string{},
-			URLs:    []string{},
+			Domains:   []string{},
+			IPs:       []string{},
+			URLs:      []string{},
+			Services:  []string{},
+			Ports:     []int{},
+			Wildcards: []string{},
 		},
 	}
 	if err := yaml.Unmarshal(data, &config); err != nil {
@@ -37,28 +45,61 @@ func parseScope(filePath string) (*scopeFileData, error) {
 
 	// Check for empty scope
 	s := config.Scope
-	if len(s.Domains) == 0 && len(s.IPs) == 0 && len(s.URLs) == 0 {
+	if len(s.Domains) == 0 && len(s.IPs) == 0 && len(s.URLs) == 0 && len(s.Services) == 0 {
 		return nil, fmt.Errorf("Failed to parse scope file at %s: the scope cannot be emtpy", filePath)
 	}
 
-	err = validateSurface(&config.Scope)
+	err = validateSurface(&config.Scope, validation.ValidateDomain)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'scope': %w", filePath, err)
 	}
 
-	err = validateSurface(&config.Exclusions)
+	// exclusions can also contain domain patterns
+	err = validateSurface(&config.Exclusions, validation.ValidateDomainPattern)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'exclusions': %w", filePath, err)
 	}
+	if len(config.Exclusions.Ports) > 0 {
+		return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'exclusions': ports cannot be excluded. Exclude a service in the format host:port instead", filePath)
+	}
+	if len(config.Exclusions.Wildcards) > 0 {
+		return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'exclusions': wildcards cannot be excluded. Exclude their domain instead", filePath)
+	}
+	if len(config.Exclusions.AssetPorts) > 0 {
+		return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'exclusions': asset ports cannot be excluded. Exclude a service in the format host:port instead", filePath)
+	}
+
+	// the ports of an asset are only probed on the assets in scope
+	for asset, ports := range config.Scope.AssetPorts {
+		if !slices.Contains(config.Scope.IPs, asset) && len(pipeline.SelectSubdomains([]string{asset}, config.Scope.Domains)) == 0 {
+			return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'scope': asset '%s' in asset_ports is not a domain or ip in scope", filePath, asset)
+		}
+		for i, port := range ports {
+			if err := validation.ValidatePort(port); err != nil {
+				return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'scope': Invalid port of asset '%s' at index %d: %w", filePath, asset, i, err)
+			}
+		}
+	}
+
+	// a wildcard declaration only affects the domains in scope
+	for i, declaration := range config.Scope.Wildcards {
+		wildcard, err := pipeline.ParseWildcard(declaration)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'scope': Invalid wildcard at index %d: %w", filePath, i, err)
+		}
+		if len(pipeline.SelectSubdomains([]string{wildcard.Domain}, config.Scope.Domains)) == 0 {
+			return nil, fmt.Errorf("Failed to parse scope file at %s: In section 'scope': wildcard '%s' at index %d is not under a domain in scope", filePath, declaration, i)
+		}
+	}
 
 	return &config, nil
 }
 
-func validateSurface(s *pipeline.Surface) error {
+func validateSurface(s *pipeline.Surface, validateDomain func(string) error) error {
 
 	// Validate domains
 	for i, domain := range s.Domains {
-		if err := validation.ValidateDomain(domain); err != nil {
+		if err := validateDomain(domain); err != nil {
 			return fmt.Errorf("Invalid domain at index %d: %w", i, err)
 		}
 	}
@@ -77,5 +118,26 @@ func validateSurface(s *pipeline.Surface) error {
 		}
 	}
 
+	// Validate services
+	for i, service := range s.Services {
+		if err := validation.ValidateService(service); err != nil {
+			return fmt.Errorf("Invalid service at index %d: %w", i, err)
+		}
+	}
+
+	// Validate wildcards
+	for i, wildcard := range s.Wildcards {
+		if err := validation.ValidateWildcard(wildcard); err != nil {
+			return fmt.Errorf("Invalid wildcard at index %d: %w", i, err)
+		}
+	}
+
+	// Validate ports
+	for i, port := range s.Ports {
+		if err := validation.ValidatePort(port); err != nil {
+			return fmt.Errorf("Invalid port at index %d: %w. Ports are probed on every domain and ip: to probe a port of a single host, list it under asset_ports, or add a service in the format host:port", i, err)
+		}
+	}
+
 	return nil
 }
@@ -20,6 +20,18 @@ func TestBadConfigurations(t *testing.T) {
 		{"empty_section", "testdata/scope/empty_section.yaml", "scope cannot be emtpy"},
 		{"empty_section2", "testdata/scope/empty_section.yaml", "scope cannot be emtpy"},
 		{"malformed_yaml", "testdata/scope/malformed_yaml.yaml", "Invalid Syntax"},
+		{"invalid_ip_range", "testdata/scope/invalid_ip_range.yaml", "invalid IP range"},
+		{"pattern_in_scope", "testdata/scope/invalid_domain_pattern.yaml", "has invalid format"},
+		{"invalid_port", "testdata/scope/invalid_port.yaml", "Invalid port at index 1"},
+		{"invalid_port_hint", "testdata/scope/invalid_port.yaml", "add a service in the format host:port"},
+		{"invalid_service", "testdata/scope/invalid_service.yaml", "must be in the format host:port"},
+		{"excluded_ports", "testdata/scope/excluded_ports.yaml", "ports cannot be excluded"},
+		{"invalid_wildcard", "testdata/scope/invalid_wildcard.yaml", "Invalid wildcard at index 1"},
+		{"wildcard_out_of_scope", "testdata/scope/wildcard_out_of_scope.yaml", "is not under a domain in scope"},
+		{"excluded_wildcards", "testdata/scope/excluded_wildcards.yaml", "wildcards cannot be excluded"},
+		{"asset_ports_out_of_scope", "testdata/scope/asset_ports_out_of_scope.yaml", "asset 'example.org' in asset_ports is not a domain or ip in scope"},
+		{"invalid_asset_port", "testdata/scope/invalid_asset_port.yaml", "Invalid port of asset 'example.com' at index 1"},
+		{"excluded_asset_ports", "testdata/scope/excluded_asset_ports.yaml", "asset ports cannot be excluded"},
 	}
 
 	for _, tt := range tests {
@@ -103,6 +115,38 @@ func TestValidConfigurations(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:     "ip_ranges",
+			filePath: "testdata/scope/valid_ip_ranges.yaml",
+			expectedData: &scopeFileData{
+				Scope: pipeline.Surface{
+					Domains: []string{},
+					IPs:     []string{"192.168.0.10-192.168.0.20", "2001:db8::1-2001:db8::ff"},
+					URLs:    []string{},
+				},
+				Exclusions: pipeline.Surface{
+					Domains: []string{},
+					IPs:     []string{"192.168.0.15-192.168.0.16"},
+					URLs:    []string{},
+				},
+			},
+		},
+		{
+			name:     "domain_patterns",
+			filePath: "testdata/scope/valid_domain_patterns.yaml",
+			expectedData: &scopeFileData{
+				Scope: pipeline.Surface{
+					Domains: []string{"example.com"},
+					IPs:     []string{},
+					URLs:    []string{},
+				},
+				Exclusions: pipeline.Surface{
+					Domains: []string{"internal.example.com", "=admin.example.com", "*.cdn.example.com"},
+					IPs:     []string{},
+					URLs:    []string{},
+				},
+			},
+		},
 		{
 			name:     "minimal_valid",
 			filePath: "testdata/scope/valid_minimal.yaml",
@@ -156,3 +200,51 @@ func TestValidConfigurations(t *testing.T) {
 		})
 	}
 }
+
+func TestValidScopePorts(t *testing.T) {
+	config, err := parseScope("testdata/scope/valid_ports.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expectedServices := []string{"admin.example.com:8443", "198.51.100.10:22", "[2001:db8::1]:8080"}
+	if !reflect.DeepEqual(config.Scope.Services, expectedServices) {
+		t.Errorf("Services mismatch.\nExpected: %v\nGot: %v", expectedServices, config.Scope.Services)
+	}
+	expectedPorts := []int{80, 443, 8080}
+	if !reflect.DeepEqual(config.Scope.Ports, expectedPorts) {
+		t.Errorf("Ports mismatch.\nExpected: %v\nGot: %v", expectedPorts, config.Scope.Ports)
+	}
+	expectedExcluded := []string{"example.com:8080"}
+	if !reflect.DeepEqual(config.Exclusions.Services, expectedExcluded) {
+		t.Errorf("Excluded services mismatch.\nExpected: %v\nGot: %v", expectedExcluded, config.Exclusions.Services)
+	}
+}
+
+func TestScopeAssetPorts(t *testing.T) {
+	config, err := parseScope("testdata/scope/valid_asset_ports.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string][]int{
+		"admin.example.com": {8443, 9000},
+		"192.0.2.10":        {22},
+		"198.51.100.0/30":   {3000},
+	}
+	if !reflect.DeepEqual(config.Scope.AssetPorts, expected) {
+		t.Errorf("Asset ports mismatch.\nExpected: %v\nGot: %v", expected, config.Scope.AssetPorts)
+	}
+}
+
+func TestScopeWildcards(t *testing.T) {
+	config, err := parseScope("testdata/scope/valid_wildcards.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []string{"*.preview.example.com", "*.*.apps.example.com"}
+	if !reflect.DeepEqual(config.Scope.Wildcards, expected) {
+		t.Errorf("Wildcards mismatch.\nExpected: %v\nGot: %v", expected, config.Scope.Wildcards)
+	}
+}
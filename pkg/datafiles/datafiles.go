@@ -3,24 +3,45 @@ package datafiles
 
 import (
 	"fmt"
-	"github.com/robalb/tinyasm/pkg/pipeline"
 	"os"
 	"path"
+	"path/filepath"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+	"github.com/robalb/tinyasm/pkg/pipeline"
 )
 
 var (
 	knownSurfaceFileName = "discovered-surface.yaml"
 	knownIssuesFileName  = "discovered-issues.yaml"
+	dnsCacheFileName     = "dns-cache.yaml"
+	overviewFileName     = "overview.md"
+	overviewYAMLFileName = "overview.yaml"
 	datafileHeader       = "## This is a program-generated data file. Do not edit. ##"
+	// overviewHeader is a comment: the yaml header would render as a heading
+	overviewHeader = "<!-- This is a program-generated file. Do not edit. -->"
 )
 
 type DataFiles struct {
+	// KnownSurface is every surface element discovered in the past
 	KnownSurface pipeline.Surface
-	// knownIssues Issues TODO
+	// LastSurface is the part of KnownSurface that was seen during the last run
+	LastSurface pipeline.Surface
+	// KnownAssets holds the provenance of every element in KnownSurface
+	KnownAssets map[string]pipeline.Asset
+	// KnownHTTP holds the last http response of every url in KnownSurface
+	KnownHTTP map[string]pipeline.HTTPInfo
+	// KnownDNS holds the last dns records of every domain in KnownSurface
+	KnownDNS map[string]pipeline.DNSRecords
+	// KnownIssues are the issues detected during the last run
+	KnownIssues []issues.Issue
+
+	dataFolder string
 }
 
 func New(dataFolder string) (d *DataFiles, fileMissing bool, err error) {
 	knownSurfaceFilePath := path.Join(dataFolder, knownSurfaceFileName)
+	knownIssuesFilePath := path.Join(dataFolder, knownIssuesFileName)
 
 	// check if the directory exists
 	if _, err := os.Stat(dataFolder); os.IsNotExist(err) {
@@ -37,21 +58,83 @@ func New(dataFolder string) (d *DataFiles, fileMissing bool, err error) {
 		return
 	}
 
+	issuesFileMissing, err := initFile(knownIssuesFilePath)
+	if err != nil {
+		return
+	}
+	fileMissing = fileMissing || issuesFileMissing
+
+	knownIssuesData, err := parseKnownIssues(knownIssuesFilePath)
+	if err != nil {
+		return
+	}
+
 	d = &DataFiles{
-		knownSurfaceData.KnownSurface,
+		KnownSurface: mergeSurfaces(knownSurfaceData.KnownSurface, knownSurfaceData.Gone),
+		LastSurface:  knownSurfaceData.KnownSurface,
+		KnownAssets:  knownSurfaceData.Assets,
+		KnownHTTP:    knownSurfaceData.HTTP,
+		KnownDNS:     knownSurfaceData.DNS,
+		KnownIssues:  knownIssuesData.Issues,
+		dataFolder:   dataFolder,
 	}
 	return
 }
 
 func (d *DataFiles) Summary() string {
 	return fmt.Sprintf(
-		"Known surface elements discovered in the past: {Domains[%d], IPs[%d], Endpoints[%d]}",
+		"Known surface elements discovered in the past: {Domains[%d], IPs[%d], Endpoints[%d], Services[%d]}, Known issues: %d",
 		len(d.KnownSurface.Domains),
 		len(d.KnownSurface.IPs),
 		len(d.KnownSurface.URLs),
+		len(d.KnownSurface.Services),
+		len(d.KnownIssues),
 	)
 }
 
+// SaveKnownSurface replaces the known surface with the outcome of a discovery run,
+// and persists it to the data folder.
+// URLs that did not respond during the run keep their last known http response,
+// and domains that were not resolved keep their last known dns records.
+func (d *DataFiles) SaveKnownSurface(discovery pipeline.Discovery) error {
+	discovery.HTTP = mergeLatest(d.KnownHTTP, discovery.HTTP, discovery.Surface.URLs)
+	discovery.DNS = mergeLatest(d.KnownDNS, discovery.DNS, discovery.Surface.Domains)
+
+	filePath := path.Join(d.dataFolder, knownSurfaceFileName)
+	err := writeKnownSurface(filePath, discovery)
+	if err != nil {
+		return err
+	}
+	d.KnownSurface = mergeSurfaces(discovery.Surface, discovery.Current)
+	d.LastSurface = mergeSurfaces(discovery.Current, pipeline.Surface{})
+	d.KnownAssets = discovery.Assets
+	d.KnownHTTP = discovery.HTTP
+	d.KnownDNS = discovery.DNS
+	return nil
+}
+
+// SaveKnownIssues replaces the known issues with the given ones,
+// and persists them to the data folder.
+func (d *DataFiles) SaveKnownIssues(detected []issues.Issue) error {
+	filePath := path.Join(d.dataFolder, knownIssuesFileName)
+	err := writeKnownIssues(filePath, detected)
+	if err != nil {
+		return err
+	}
+	d.KnownIssues = detected
+	return nil
+}
+
+// SaveOverview writes the Markdown and YAML overviews of the last run to the data folder
+func (d *DataFiles) SaveOverview(markdown []byte, yamlOverview []byte) error {
+	filePath := path.Join(d.dataFolder, overviewFileName)
+	if err := WriteFileAtomic(filePath, overviewHeader, markdown); err != nil {
+		return err
+	}
+	filePath = path.Join(d.dataFolder, overviewYAMLFileName)
+	return WriteFileAtomic(filePath, datafileHeader, yamlOverview)
+}
+
 func initFile(filePath string) (fileMissing bool, err error) {
 	// Check if file exists
 	_, err = os.Stat(filePath)
@@ -75,3 +158,41 @@ func initFile(filePath string) (fileMissing bool, err error) {
 	// File already exists
 	return false, nil
 }
+
+// WriteFileAtomic writes header followed by content to filePath.
+// The data is first written to a temporary file in the same directory, which is
+// then renamed over the destination, so that an interrupted write never leaves
+// a truncated file behind.
+func WriteFileAtomic(filePath string, header string, content []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", filePath, err)
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err = tmp.WriteString(header + "\n"); err != nil {
+		return fmt.Errorf("failed to write content to file %s: %w", tmpPath, err)
+	}
+	if _, err = tmp.Write(content); err != nil {
+		return fmt.Errorf("failed to write content to file %s: %w", tmpPath, err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file %s: %w", tmpPath, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s: %w", tmpPath, err)
+	}
+	if err = os.Chmod(tmpPath, 0o644); err != nil {
+		return fmt.Errorf("failed to set permissions on file %s: %w", tmpPath, err)
+	}
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace file %s: %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package datafiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveOverview(t *testing.T) {
+	d := &DataFiles{dataFolder: t.TempDir()}
+	if err := d.SaveOverview([]byte("# Surface overview\n"), []byte("issues: {}\n")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(d.dataFolder, overviewFileName))
+	if err != nil {
+		t.Fatalf("Failed to read the overview: %v", err)
+	}
+	expected := overviewHeader + "\n# Surface overview\n"
+	if string(data) != expected {
+		t.Errorf("Overview = %q, want %q", data, expected)
+	}
+
+	data, err = os.ReadFile(filepath.Join(d.dataFolder, overviewYAMLFileName))
+	if err != nil {
+		t.Fatalf("Failed to read the yaml overview: %v", err)
+	}
+	expected = datafileHeader + "\nissues: {}\n"
+	if string(data) != expected {
+		t.Errorf("YAML overview = %q, want %q", data, expected)
+	}
+}
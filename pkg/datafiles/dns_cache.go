@@ -0,0 +1,71 @@
+package datafiles
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// dnsCacheVersion is bumped whenever the format of the dns cache file changes.
+// Files with a different version are ignored, and overwritten.
+const dnsCacheVersion = 1
+
+type dnsCacheFileData struct {
+	Version int                               `yaml:"version"`
+	Entries map[string]pipeline.DNSCacheEntry `yaml:"entries"`
+}
+
+// LoadDNSCache reads the dns cache persisted by a previous run.
+// The cache is optional: a missing file, or a file written by a different
+// version of the program, results in an empty cache.
+func (d *DataFiles) LoadDNSCache() (map[string]pipeline.DNSCacheEntry, error) {
+	return parseDNSCache(path.Join(d.dataFolder, dnsCacheFileName))
+}
+
+// SaveDNSCache persists the dns cache to the data folder
+func (d *DataFiles) SaveDNSCache(entries map[string]pipeline.DNSCacheEntry) error {
+	return writeDNSCache(path.Join(d.dataFolder, dnsCacheFileName), entries)
+}
+
+func parseDNSCache(filePath string) (map[string]pipeline.DNSCacheEntry, error) {
+	entries := map[string]pipeline.DNSCacheEntry{}
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return entries, fmt.Errorf("Failed to read dns cache file at %s: %w", filePath, err)
+	}
+
+	cache := dnsCacheFileData{}
+	if err := yaml.Unmarshal(data, &cache); err != nil {
+		return entries, fmt.Errorf("Failed to parse dns cache file at %s: Invalid Syntax: %w", filePath, err)
+	}
+	if cache.Version != dnsCacheVersion || cache.Entries == nil {
+		return entries, nil
+	}
+	return cache.Entries, nil
+}
+
+// writeDNSCache serializes the dns cache to filePath.
+// Entries are sorted by domain, by the yaml encoder.
+func writeDNSCache(filePath string, entries map[string]pipeline.DNSCacheEntry) error {
+	cache := dnsCacheFileData{
+		Version: dnsCacheVersion,
+		Entries: entries,
+	}
+	if cache.Entries == nil {
+		cache.Entries = map[string]pipeline.DNSCacheEntry{}
+	}
+
+	data, err := yaml.Marshal(&cache)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize dns cache file at %s: %w", filePath, err)
+	}
+
+	return WriteFileAtomic(filePath, datafileHeader, data)
+}
@@ -0,0 +1,75 @@
+package datafiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func TestDNSCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	d, _, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// the cache is optional
+	entries, err := d.LoadDNSCache()
+	if err != nil {
+		t.Fatalf("Expected no error for a missing cache, got: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty cache, got %v", entries)
+	}
+
+	expires := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries = map[string]pipeline.DNSCacheEntry{
+		"example.com":          {IPs: []string{"192.0.2.1", "2001:db8::1"}, Expires: expires},
+		"nxdomain.example.com": {IPs: []string{}, Expires: expires},
+	}
+	if err := d.SaveDNSCache(entries); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	loaded, err := d.LoadDNSCache()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, entries) {
+		t.Errorf("Cache mismatch.\nExpected: %v\nGot: %v", entries, loaded)
+	}
+}
+
+func TestDNSCacheInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"other_version", "version: 99\nentries:\n  example.com:\n    ips: [192.0.2.1]\n", false},
+		{"empty", "", false},
+		{"malformed", "version: [", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), dnsCacheFileName)
+			if err := os.WriteFile(filePath, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("Failed to write the cache file: %v", err)
+			}
+
+			entries, err := parseDNSCache(filePath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDNSCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// unusable caches are always empty
+			if len(entries) != 0 {
+				t.Errorf("Expected an empty cache, got %v", entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package datafiles
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+	"github.com/robalb/tinyasm/pkg/validation"
+	"gopkg.in/yaml.v3"
+)
+
+type knownIssuesFileData struct {
+	// the issues detected during the last run, excluding the ignored ones
+	Issues []issues.Issue `yaml:"issues"`
+}
+
+func parseKnownIssues(filePath string) (*knownIssuesFileData, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read known-issues file at %s: %w", filePath, err)
+	}
+
+	knownIssues := knownIssuesFileData{
+		Issues: []issues.Issue{},
+	}
+	if err := yaml.Unmarshal(data, &knownIssues); err != nil {
+		return nil, fmt.Errorf("Failed to parse known-issues file at %s: Invalid Syntax: %w", filePath, err)
+	}
+	if knownIssues.Issues == nil {
+		knownIssues.Issues = []issues.Issue{}
+	}
+
+	for i, issue := range knownIssues.Issues {
+		if err := validation.ValidateIssueID(issue.ID); err != nil {
+			return nil, fmt.Errorf("Failed to parse known-issues file at %s: Invalid issue at index %d: %w", filePath, i, err)
+		}
+	}
+
+	return &knownIssues, nil
+}
+
+// writeKnownIssues serializes the issues to filePath, sorted by identifier
+func writeKnownIssues(filePath string, detected []issues.Issue) error {
+	knownIssues := knownIssuesFileData{
+		Issues: slices.Clone(detected),
+	}
+	if knownIssues.Issues == nil {
+		knownIssues.Issues = []issues.Issue{}
+	}
+	issues.Sort(knownIssues.Issues)
+
+	data, err := yaml.Marshal(&knownIssues)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize known-issues file at %s: %w", filePath, err)
+	}
+
+	return WriteFileAtomic(filePath, datafileHeader, data)
+}
@@ -0,0 +1,60 @@
+package datafiles
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+func TestWriteKnownIssues(t *testing.T) {
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	withTimes := func(issue issues.Issue) issues.Issue {
+		issue.FirstSeen = seen
+		issue.LastSeen = seen
+		return issue
+	}
+
+	tests := []struct {
+		name     string
+		issues   []issues.Issue
+		expected []issues.Issue
+	}{
+		{
+			name:     "no issues",
+			issues:   nil,
+			expected: []issues.Issue{},
+		},
+		{
+			name: "sorted by identifier",
+			issues: []issues.Issue{
+				withTimes(issues.New("takeover", "b.example.com", "aws-s3", "dangling CNAME")),
+				withTimes(issues.New("takeover", "a.example.com", "aws-s3", "dangling CNAME")),
+			},
+			expected: []issues.Issue{
+				withTimes(issues.New("takeover", "a.example.com", "aws-s3", "dangling CNAME")),
+				withTimes(issues.New("takeover", "b.example.com", "aws-s3", "dangling CNAME")),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), knownIssuesFileName)
+
+			if err := writeKnownIssues(filePath, tt.issues); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			parsed, err := parseKnownIssues(filePath)
+			if err != nil {
+				t.Fatalf("Failed to parse the written file: %v", err)
+			}
+			if !reflect.DeepEqual(parsed.Issues, tt.expected) {
+				t.Errorf("Issues mismatch.\nExpected: %v\nGot: %v", tt.expected, parsed.Issues)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package datafiles
 import (
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/robalb/tinyasm/pkg/pipeline"
 	"github.com/robalb/tinyasm/pkg/validation"
@@ -10,7 +11,16 @@ import (
 )
 
 type knownSurfaceFileData struct {
+	// the surface seen during the last run
 	KnownSurface pipeline.Surface `yaml:"surface"`
+	// the surface discovered in the past, but not seen during the last run
+	Gone pipeline.Surface `yaml:"gone"`
+	// the provenance of every element in surface and gone
+	Assets map[string]pipeline.Asset `yaml:"assets"`
+	// the last http response of every url in surface and gone
+	HTTP map[string]pipeline.HTTPInfo `yaml:"http"`
+	// the last dns records of every domain in surface and gone
+	DNS map[string]pipeline.DNSRecords `yaml:"dns"`
 }
 
 func parseKnownSurface(filePath string) (*knownSurfaceFileData, error) {
@@ -21,38 +31,155 @@ func parseKnownSurface(filePath string) (*knownSurfaceFileData, error) {
 
 	surface := knownSurfaceFileData{
 		KnownSurface: pipeline.Surface{
-			Domains: []string{},
-			IPs:     []string{},
-			URLs:    []string{},
+			Domains:  []string{},
+			IPs:      []string{},
+			URLs:     []string{},
+			Services: []string{},
 		},
+		Gone: pipeline.Surface{
+			Domains:  []string{},
+			IPs:      []string{},
+			URLs:     []string{},
+			Services: []string{},
+		},
+		Assets: map[string]pipeline.Asset{},
+		HTTP:   map[string]pipeline.HTTPInfo{},
+		DNS:    map[string]pipeline.DNSRecords{},
 	}
 	if err := yaml.Unmarshal(data, &surface); err != nil {
 		return nil, fmt.Errorf("Failed to parse known-surface file at %s: Invalid Syntax: %w", filePath, err)
 	}
 
-	s := surface.KnownSurface
+	err = validateSurface(&surface.KnownSurface)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse known-surface file at %s: In section 'surface': %w", filePath, err)
+	}
+
+	err = validateSurface(&surface.Gone)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse known-surface file at %s: In section 'gone': %w", filePath, err)
+	}
+
+	if surface.Assets == nil {
+		surface.Assets = map[string]pipeline.Asset{}
+	}
+	if surface.HTTP == nil {
+		surface.HTTP = map[string]pipeline.HTTPInfo{}
+	}
+	if surface.DNS == nil {
+		surface.DNS = map[string]pipeline.DNSRecords{}
+	}
+
+	return &surface, nil
+
+}
+
+func validateSurface(s *pipeline.Surface) error {
 
 	// Validate domains
 	for i, domain := range s.Domains {
 		if err := validation.ValidateDomain(domain); err != nil {
-			return nil, fmt.Errorf("Invalid domain at index %d: %w", i, err)
+			return fmt.Errorf("Invalid domain at index %d: %w", i, err)
 		}
 	}
 
 	// Validate IPs
 	for i, ip := range s.IPs {
 		if err := validation.ValidateIP(ip); err != nil {
-			return nil, fmt.Errorf("Invalid IP at index %d: %w", i, err)
+			return fmt.Errorf("Invalid IP at index %d: %w", i, err)
 		}
 	}
 
 	// Validate URLs
 	for i, url := range s.URLs {
 		if err := validation.ValidateURL(url); err != nil {
-			return nil, fmt.Errorf("Invalid url at index %d: %w", i, err)
+			return fmt.Errorf("Invalid url at index %d: %w", i, err)
 		}
 	}
 
-	return &surface, nil
+	// Validate services
+	for i, service := range s.Services {
+		if err := validation.ValidateService(service); err != nil {
+			return fmt.Errorf("Invalid service at index %d: %w", i, err)
+		}
+	}
 
+	return nil
+}
+
+// writeKnownSurface serializes the outcome of a discovery run to filePath.
+// The part of the surface that was seen during the run is written in the 'surface'
+// section, everything else is written in the 'gone' section.
+// Every list is sorted and deduplicated, so that two runs that discover the
+// same surface produce byte-identical files, and a clean git diff.
+func writeKnownSurface(filePath string, discovery pipeline.Discovery) error {
+	merged := discovery.Surface
+	current := discovery.Current
+	surface := knownSurfaceFileData{
+		KnownSurface: pipeline.Surface{
+			Domains:  sortedUnique(current.Domains),
+			IPs:      sortedUnique(current.IPs),
+			URLs:     sortedUnique(current.URLs),
+			Services: sortedUnique(current.Services),
+		},
+		Gone: pipeline.Surface{
+			Domains:  sortedUnique(pipeline.Subtract(merged.Domains, current.Domains)),
+			IPs:      sortedUnique(pipeline.Subtract(merged.IPs, current.IPs)),
+			URLs:     sortedUnique(pipeline.Subtract(merged.URLs, current.URLs)),
+			Services: sortedUnique(pipeline.Subtract(merged.Services, current.Services)),
+		},
+		Assets: map[string]pipeline.Asset{},
+		HTTP:   map[string]pipeline.HTTPInfo{},
+		DNS:    map[string]pipeline.DNSRecords{},
+	}
+	for value, asset := range discovery.Assets {
+		asset.Sources = sortedUnique(asset.Sources)
+		surface.Assets[value] = asset
+	}
+	for url, info := range discovery.HTTP {
+		surface.HTTP[url] = info
+	}
+	for domain, records := range discovery.DNS {
+		surface.DNS[domain] = records
+	}
+
+	data, err := yaml.Marshal(&surface)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize known-surface file at %s: %w", filePath, err)
+	}
+
+	return WriteFileAtomic(filePath, datafileHeader, data)
+}
+
+// mergeSurfaces returns the sorted union of two surfaces
+func mergeSurfaces(a pipeline.Surface, b pipeline.Surface) pipeline.Surface {
+	return pipeline.Surface{
+		Domains:  sortedUnique(append(slices.Clone(a.Domains), b.Domains...)),
+		IPs:      sortedUnique(append(slices.Clone(a.IPs), b.IPs...)),
+		URLs:     sortedUnique(append(slices.Clone(a.URLs), b.URLs...)),
+		Services: sortedUnique(append(slices.Clone(a.Services), b.Services...)),
+	}
+}
+
+// sortedUnique returns a sorted copy of values, without duplicates.
+// The returned slice is never nil.
+func sortedUnique(values []string) []string {
+	result := make([]string, 0, len(values))
+	result = append(result, values...)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// mergeLatest returns the values of the given keys,
+// taken from current when available, and from known otherwise
+func mergeLatest[T any](known map[string]T, current map[string]T, keys []string) map[string]T {
+	merged := make(map[string]T, len(keys))
+	for _, key := range keys {
+		if value, exists := current[key]; exists {
+			merged[key] = value
+		} else if value, exists := known[key]; exists {
+			merged[key] = value
+		}
+	}
+	return merged
 }
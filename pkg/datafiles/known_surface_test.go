@@ -0,0 +1,273 @@
+package datafiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func TestWriteKnownSurface(t *testing.T) {
+	tests := []struct {
+		name     string
+		surface  pipeline.Surface
+		expected pipeline.Surface
+	}{
+		{
+			name:    "empty surface",
+			surface: pipeline.Surface{},
+			expected: pipeline.Surface{
+				Domains:  []string{},
+				IPs:      []string{},
+				URLs:     []string{},
+				Services: []string{},
+			},
+		},
+		{
+			name: "sorted and deduplicated",
+			surface: pipeline.Surface{
+				Domains:  []string{"b.example.com", "a.example.com", "b.example.com"},
+				IPs:      []string{"10.0.0.2", "10.0.0.1"},
+				URLs:     []string{"https://example.com/b", "https://example.com/a"},
+				Services: []string{"example.com:8443", "192.0.2.1:22", "example.com:8443"},
+			},
+			expected: pipeline.Surface{
+				Domains:  []string{"a.example.com", "b.example.com"},
+				IPs:      []string{"10.0.0.1", "10.0.0.2"},
+				URLs:     []string{"https://example.com/a", "https://example.com/b"},
+				Services: []string{"192.0.2.1:22", "example.com:8443"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, knownSurfaceFileName)
+
+			if err := writeKnownSurface(filePath, pipeline.Discovery{Surface: tt.surface, Current: tt.surface}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatalf("Failed to read the written file: %v", err)
+			}
+			if !strings.HasPrefix(string(data), datafileHeader) {
+				t.Errorf("Written file does not start with the datafile header:\n%s", data)
+			}
+
+			parsed, err := parseKnownSurface(filePath)
+			if err != nil {
+				t.Fatalf("Failed to parse the written file: %v", err)
+			}
+			if !reflect.DeepEqual(parsed.KnownSurface, tt.expected) {
+				t.Errorf("Surface mismatch.\nExpected: %v\nGot: %v", tt.expected, parsed.KnownSurface)
+			}
+
+			// Only the data file should be left in the folder
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("Failed to read the data folder: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("Expected only the data file in the folder, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriteKnownSurfaceDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.yaml")
+	second := filepath.Join(dir, "second.yaml")
+
+	s := pipeline.Surface{
+		Domains: []string{"b.example.com", "a.example.com"},
+		IPs:     []string{"10.0.0.1"},
+	}
+	err := writeKnownSurface(first, pipeline.Discovery{Surface: s, Current: s})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	s = pipeline.Surface{
+		Domains: []string{"a.example.com", "b.example.com", "a.example.com"},
+		IPs:     []string{"10.0.0.1"},
+	}
+	err = writeKnownSurface(second, pipeline.Discovery{Surface: s, Current: s})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	firstData, _ := os.ReadFile(first)
+	secondData, _ := os.ReadFile(second)
+	if string(firstData) != string(secondData) {
+		t.Errorf("Equivalent surfaces produced different files.\nFirst:\n%s\nSecond:\n%s", firstData, secondData)
+	}
+}
+
+func TestSaveKnownSurface(t *testing.T) {
+	dir := t.TempDir()
+
+	d, fileMissing, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !fileMissing {
+		t.Errorf("Expected the data file to be reported as missing")
+	}
+
+	known := pipeline.Surface{
+		Domains:  []string{"example.com", "old.example.com"},
+		IPs:      []string{},
+		URLs:     []string{},
+		Services: []string{},
+	}
+	current := pipeline.Surface{
+		Domains:  []string{"example.com"},
+		IPs:      []string{},
+		URLs:     []string{},
+		Services: []string{},
+	}
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	assets := map[string]pipeline.Asset{
+		"example.com": {
+			Sources:   []string{"scope", "subfinder"},
+			FirstSeen: seen.Add(-time.Hour),
+			LastSeen:  seen,
+		},
+		"old.example.com": {
+			Sources:   []string{"alterx"},
+			FirstSeen: seen.Add(-time.Hour),
+			LastSeen:  seen.Add(-time.Hour),
+		},
+	}
+	discovery := pipeline.Discovery{Surface: known, Current: current, Assets: assets}
+	if err := d.SaveKnownSurface(discovery); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	reloaded, fileMissing, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if fileMissing {
+		t.Errorf("Expected the data file to exist")
+	}
+	if !reflect.DeepEqual(reloaded.KnownSurface, known) {
+		t.Errorf("Known surface mismatch.\nExpected: %v\nGot: %v", known, reloaded.KnownSurface)
+	}
+	if !reflect.DeepEqual(reloaded.LastSurface, current) {
+		t.Errorf("Last surface mismatch.\nExpected: %v\nGot: %v", current, reloaded.LastSurface)
+	}
+	if !reflect.DeepEqual(reloaded.KnownAssets, assets) {
+		t.Errorf("Assets mismatch.\nExpected: %v\nGot: %v", assets, reloaded.KnownAssets)
+	}
+}
+
+func TestSaveKnownSurfaceHTTP(t *testing.T) {
+	dir := t.TempDir()
+	d, _, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expiry := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	app := pipeline.HTTPInfo{
+		StatusCode:   200,
+		Title:        "App",
+		BodyHash:     "aaaa",
+		Technologies: []string{"Nginx"},
+		TLS:          &pipeline.TLSInfo{Subject: "app.example.com", SANs: []string{"app.example.com"}, NotAfter: expiry},
+		ResponseTime: 120 * time.Millisecond,
+	}
+	ci := pipeline.HTTPInfo{StatusCode: 403, Redirects: []string{"https://ci.example.com/login"}}
+	urls := []string{"https://app.example.com", "https://ci.example.com"}
+	s := pipeline.Surface{URLs: urls}
+	err = d.SaveKnownSurface(pipeline.Discovery{
+		Surface: s,
+		Current: s,
+		HTTP:    map[string]pipeline.HTTPInfo{urls[0]: app, urls[1]: ci},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// ci did not respond: its last response is kept.
+	// app changed, and the new response replaces the old one
+	app.Title = "App v2"
+	err = d.SaveKnownSurface(pipeline.Discovery{
+		Surface: s,
+		Current: pipeline.Surface{URLs: urls[:1]},
+		HTTP:    map[string]pipeline.HTTPInfo{urls[0]: app},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	reloaded, _, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := map[string]pipeline.HTTPInfo{urls[0]: app, urls[1]: ci}
+	if !reflect.DeepEqual(reloaded.KnownHTTP, expected) {
+		t.Errorf("HTTP mismatch.\nExpected: %+v\nGot: %+v", expected, reloaded.KnownHTTP)
+	}
+
+	// urls that are no longer part of the surface lose their response
+	s = pipeline.Surface{URLs: urls[:1]}
+	if err := d.SaveKnownSurface(pipeline.Discovery{Surface: s, Current: s}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, exists := d.KnownHTTP[urls[1]]; exists {
+		t.Errorf("Expected the response of %s to be dropped", urls[1])
+	}
+}
+
+func TestSaveKnownSurfaceDNS(t *testing.T) {
+	dir := t.TempDir()
+	d, _, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	records := map[string]pipeline.DNSRecords{
+		"example.com": {
+			A:   []string{"192.0.2.1"},
+			NS:  []string{"ns1.example.net"},
+			MX:  []string{"10 mx.example.com"},
+			TXT: []string{"v=spf1 -all"},
+		},
+		"www.example.com": {
+			CNAME: []string{"example.github.io"},
+			A:     []string{"185.199.108.153"},
+		},
+	}
+	domains := []string{"example.com", "www.example.com"}
+	s := pipeline.Surface{Domains: domains}
+	if err := d.SaveKnownSurface(pipeline.Discovery{Surface: s, Current: s, DNS: records}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// www was not resolved in this run: its last records are kept
+	err = d.SaveKnownSurface(pipeline.Discovery{
+		Surface: s,
+		Current: pipeline.Surface{Domains: domains[:1]},
+		DNS:     map[string]pipeline.DNSRecords{"example.com": records["example.com"]},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	reloaded, _, err := New(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.KnownDNS, records) {
+		t.Errorf("DNS mismatch.\nExpected: %+v\nGot: %+v", records, reloaded.KnownDNS)
+	}
+}
@@ -0,0 +1,118 @@
+package editor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+// EnvFunc initializes the state of a debug run
+type EnvFunc func(logger *slog.Logger) *pipeline.Env
+
+// ValueInfo is a value read or written by a node during a debug run
+type ValueInfo struct {
+	Count int `json:"count"`
+	Value any `json:"value"`
+}
+
+// NodeEvent is sent when a node of a debug run finishes
+type NodeEvent struct {
+	ID         string               `json:"id"`
+	Operator   string               `json:"operator"`
+	Inputs     map[string]ValueInfo `json:"inputs"`
+	Outputs    map[string]ValueInfo `json:"outputs"`
+	DurationMs int64                `json:"duration_ms"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// EnableDebug allows the editor to run pipelines, with the state returned by newEnv.
+// Debug runs start actual scans, against the scope in the config folder.
+// Nothing they discover is saved.
+func (s *Server) EnableDebug(newEnv EnvFunc) {
+	s.newEnv = newEnv
+}
+
+// handleDebugRun runs a pipeline, and streams the inputs and outputs of every node
+// as server-sent events while the nodes finish.
+// The run is cancelled as soon as the client disconnects.
+func (s *Server) handleDebugRun(w http.ResponseWriter, r *http.Request) {
+	if s.newEnv == nil {
+		http.Error(w, "debug mode is disabled", http.StatusForbidden)
+		return
+	}
+	definition, err := readDefinition(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ValidationResult{Error: err.Error()})
+		return
+	}
+	// debug pipelines don't need to collect a discovery: any valid graph can run
+	nodes, err := definition.Compile(s.config)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ValidationResult{Error: err.Error()})
+		return
+	}
+
+	// a single run at a time: concurrent runs would share the scanners rate limits.
+	// The lock is held until RunNodes returns, which on cancellation
+	// waits for the probes in flight, so no scan of this run outlives it
+	if !s.running.TryLock() {
+		http.Error(w, "a debug run is already in progress", http.StatusConflict)
+		return
+	}
+	defer s.running.Unlock()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			s.logger.Error("Failed to encode a debug event", "event", event, "error", err)
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		_ = rc.Flush()
+	}
+
+	order := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		order = append(order, node.ID)
+	}
+	send("start", map[string]any{"order": order})
+
+	s.logger.Info("Debug run started", "nodes", len(nodes))
+	env := s.newEnv(s.logger)
+	err = pipeline.RunNodes(r.Context(), env, nodes, func(result pipeline.NodeResult) {
+		event := NodeEvent{
+			ID:         result.Node.ID,
+			Operator:   result.Node.Operator,
+			Inputs:     valueInfos(result.Inputs),
+			Outputs:    valueInfos(result.Outputs),
+			DurationMs: result.Duration.Milliseconds(),
+		}
+		if result.Err != nil {
+			event.Error = result.Err.Error()
+		}
+		send("node", event)
+	})
+	if err != nil {
+		s.logger.Warn("Debug run failed", "error", err)
+		send("error", map[string]string{"error": err.Error()})
+		return
+	}
+	s.logger.Info("Debug run done")
+	send("done", map[string]any{})
+}
+
+func valueInfos(values pipeline.Values) map[string]ValueInfo {
+	infos := map[string]ValueInfo{}
+	for name, v := range values {
+		infos[name] = ValueInfo{Count: pipeline.Count(v), Value: v}
+	}
+	return infos
+}
@@ -0,0 +1,174 @@
+package editor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+const debugGraph = `{
+  "nodes": [
+    {"id": "scope", "operator": "scope"},
+    {"id": "split", "operator": "split_surface"},
+    {"id": "trim", "operator": "trim_subdomains"}
+  ],
+  "edges": [
+    {"from": "scope.surface", "to": "split.surface"},
+    {"from": "split.domains", "to": "trim.domains"}
+  ]
+}`
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, body io.Reader) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestDebugRunDisabled(t *testing.T) {
+	server, _ := testServer(t)
+	status, body := do(t, http.MethodPost, server.URL+"/api/debug/run", debugGraph)
+	if status != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d: %s", status, body)
+	}
+}
+
+func TestDebugRun(t *testing.T) {
+	config := pipeline.DefaultConfig()
+	scope := pipeline.Surface{Domains: []string{"example.com", "www.example.com", "example.org"}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New(logger, &config, t.TempDir()+"/pipeline.yaml", t.TempDir(), "")
+	s.EnableDebug(func(logger *slog.Logger) *pipeline.Env {
+		return pipeline.NewEnv(logger, pipeline.NewDNSCache(), &pipeline.Surface{}, nil, &scope, &pipeline.Surface{}, &config)
+	})
+	server := httptestServer(t, s)
+
+	status, body := do(t, http.MethodPost, server.URL+"/api/debug/run", `{"nodes": [{"id": "a", "operator": "nmap"}]}`)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected an invalid graph to be rejected, got %d: %s", status, body)
+	}
+
+	// the simple request any web page can send without a preflight
+	resp, err := http.Post(server.URL+"/api/debug/run", "text/plain", strings.NewReader(debugGraph))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("Expected a text/plain run to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/api/debug/run", "application/json", strings.NewReader(debugGraph))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", ct)
+	}
+
+	events := readEvents(t, resp.Body)
+	var names []string
+	for _, e := range events {
+		names = append(names, e.name)
+	}
+	expected := []string{"start", "node", "node", "node", "done"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected events %v, got %v", expected, names)
+	}
+
+	var trim NodeEvent
+	if err := json.Unmarshal([]byte(events[3].data), &trim); err != nil {
+		t.Fatalf("Failed to decode the node event: %v", err)
+	}
+	if trim.ID != "trim" || trim.Inputs["domains"].Count != 3 || trim.Outputs["domains"].Count != 2 {
+		t.Errorf("Unexpected node event: %+v", trim)
+	}
+	domains, _ := trim.Outputs["domains"].Value.([]any)
+	if len(domains) != 2 {
+		t.Errorf("Expected the values of the outputs, got %v", trim.Outputs["domains"].Value)
+	}
+}
+
+func TestDebugRunCancelled(t *testing.T) {
+	// the server never answers, until the probes time out
+	probed := make(chan struct{}, 100)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probed <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer target.Close()
+
+	config := pipeline.DefaultConfig()
+	config.Httpx.Timeout = 2
+	scope := pipeline.Surface{URLs: []string{target.URL}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New(logger, &config, t.TempDir()+"/pipeline.yaml", t.TempDir(), "")
+	s.EnableDebug(func(logger *slog.Logger) *pipeline.Env {
+		return pipeline.NewEnv(logger, pipeline.NewDNSCache(), &pipeline.Surface{}, nil, &scope, &pipeline.Surface{}, &config)
+	})
+	server := httptestServer(t, s)
+
+	graph := `{
+  "nodes": [{"id": "scope", "operator": "scope"}, {"id": "httpx", "operator": "httpx"}],
+  "edges": [{"from": "scope.surface", "to": "httpx.surface"}]
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/debug/run", strings.NewReader(graph))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	<-probed
+	cancel()
+
+	// the run is over only once its probes are
+	time.Sleep(500 * time.Millisecond)
+	status, body := do(t, http.MethodPost, server.URL+"/api/debug/run", graph)
+	if status != http.StatusConflict {
+		t.Fatalf("Expected the cancelled run to hold the lock while probing, got %d: %s", status, body)
+	}
+	deadline := time.Now().Add(15 * time.Second)
+	for !s.running.TryLock() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the cancelled run to release the lock")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.running.Unlock()
+}
@@ -0,0 +1,274 @@
+// Package editor is the backend of the web pipeline editor.
+// It serves the built web app, the catalog of the registered operators,
+// and validates and saves the pipelines designed in the editor, in the
+// same format asm reads from the config folder.
+// In debug mode, it also runs pipelines, streaming the output of every node.
+package editor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/robalb/tinyasm/pkg/datafiles"
+	"github.com/robalb/tinyasm/pkg/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// maxGraphSize is the largest pipeline definition the server accepts
+const maxGraphSize = 1 << 20
+
+// pipelineHeader is written at the top of the saved pipelines.
+// They are encoded again from the graph: the comments of the previous file are lost
+const pipelineHeader = "# Saved by the TinyASM pipeline editor. Comments are not kept when the editor saves this file again"
+
+// OperatorInfo describes an operator to the editor
+type OperatorInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Inputs      []pipeline.Socket      `json:"inputs"`
+	Outputs     []pipeline.Socket      `json:"outputs"`
+	Config      []pipeline.ConfigField `json:"config"`
+}
+
+// ValidationResult is the outcome of the validation of a pipeline
+type ValidationResult struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Order is the execution order of the nodes of a valid pipeline
+	Order []string `json:"order,omitempty"`
+}
+
+type Server struct {
+	logger *slog.Logger
+	config *pipeline.Config
+	// pipelinePath is the pipeline file asm reads, that the editor loads and saves
+	pipelinePath string
+	// webFolder holds the built web app
+	webFolder string
+	// address is the address the editor listens on.
+	// Besides the loopback ones, its host is the only one requests can be sent to
+	address string
+	// newEnv is set when debug runs are enabled
+	newEnv  EnvFunc
+	running sync.Mutex
+}
+
+func New(logger *slog.Logger, config *pipeline.Config, pipelinePath string, webFolder string, address string) *Server {
+	return &Server{
+		logger:       logger,
+		config:       config,
+		pipelinePath: pipelinePath,
+		webFolder:    webFolder,
+		address:      address,
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/operators", s.handleOperators)
+	mux.HandleFunc("GET /api/pipeline", s.handleGetPipeline)
+	mux.HandleFunc("PUT /api/pipeline", s.handleSavePipeline)
+	mux.HandleFunc("POST /api/pipeline/validate", s.handleValidatePipeline)
+	mux.HandleFunc("POST /api/debug/run", s.handleDebugRun)
+	mux.Handle("GET /", http.FileServer(http.Dir(s.webFolder)))
+	return sameOrigin(s.address, mux)
+}
+
+// sameOrigin rejects the requests that change state, unless they are sent by the
+// editor itself, as json.
+// The editor listens on localhost, but any web page the operator visits can send
+// it requests: a cross-origin POST with a text/plain body needs no preflight, and
+// would be enough to overwrite the pipeline or to start a debug scan.
+// Requiring a json body forces a preflight, that the server never allows, and the
+// Origin and Sec-Fetch-Site headers tell the browser requests of other sites apart.
+// A site can also rebind its own domain to 127.0.0.1, and become same-origin with the
+// editor: all the requests, reads included, must be sent to a loopback host or to
+// the host of the address the editor listens on.
+func sameOrigin(address string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHost(r.Host, address) {
+			http.Error(w, "unexpected host", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			http.Error(w, "the request body must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether host, the Host of a request, is a loopback name or ip,
+// or the host of address
+func allowedHost(host string, address string) bool {
+	host = hostname(host)
+	if host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	// an unspecified address, like 0.0.0.0, listens on every interface but names none
+	allowed := hostname(address)
+	if ip := net.ParseIP(allowed); allowed == "" || (ip != nil && ip.IsUnspecified()) {
+		return false
+	}
+	return strings.EqualFold(host, allowed)
+}
+
+// hostname strips the port, and the brackets of ipv6 addresses, from a host
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// Catalog returns the description of all the registered operators
+func Catalog() []OperatorInfo {
+	catalog := []OperatorInfo{}
+	for _, op := range pipeline.Operators() {
+		catalog = append(catalog, OperatorInfo{
+			Name:        op.Name(),
+			Description: op.Description(),
+			Inputs:      nonNil(op.Inputs()),
+			Outputs:     nonNil(op.Outputs()),
+			Config:      nonNil(op.Config()),
+		})
+	}
+	return catalog
+}
+
+func (s *Server) handleOperators(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Catalog())
+}
+
+// handleGetPipeline returns the pipeline asm would run: the one in the config folder,
+// or the built-in one
+func (s *Server) handleGetPipeline(w http.ResponseWriter, r *http.Request) {
+	definition := pipeline.DefaultDefinition()
+	data, err := os.ReadFile(s.pipelinePath)
+	if err != nil && !os.IsNotExist(err) {
+		s.logger.Error("Failed to read the pipeline file", "path", s.pipelinePath, "error", err)
+		http.Error(w, "failed to read the pipeline file", http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		definition, err = pipeline.ParseDefinition(data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("the pipeline file is invalid: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, definition)
+}
+
+func (s *Server) handleValidatePipeline(w http.ResponseWriter, r *http.Request) {
+	definition, err := readDefinition(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ValidationResult{Error: err.Error()})
+		return
+	}
+	result := s.validate(definition)
+	status := http.StatusOK
+	if !result.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, result)
+}
+
+// handleSavePipeline writes a valid pipeline into the config folder, where asm reads it.
+// The file is replaced atomically, so that asm never reads a truncated pipeline
+func (s *Server) handleSavePipeline(w http.ResponseWriter, r *http.Request) {
+	definition, err := readDefinition(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ValidationResult{Error: err.Error()})
+		return
+	}
+	result := s.validate(definition)
+	if !result.Valid {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	data, err := yaml.Marshal(definition)
+	if err != nil {
+		s.logger.Error("Failed to encode the pipeline", "error", err)
+		http.Error(w, "failed to encode the pipeline", http.StatusInternalServerError)
+		return
+	}
+	if err := datafiles.WriteFileAtomic(s.pipelinePath, pipelineHeader, data); err != nil {
+		s.logger.Error("Failed to write the pipeline file", "path", s.pipelinePath, "error", err)
+		http.Error(w, "failed to write the pipeline file", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("Pipeline saved", "path", s.pipelinePath, "nodes", len(definition.Nodes))
+	writeJSON(w, http.StatusOK, result)
+}
+
+// validate checks a pipeline the same way asm does at startup
+func (s *Server) validate(definition *pipeline.Definition) ValidationResult {
+	nodes, err := pipeline.CompileSurfaceDiscovery(definition, s.config)
+	if err != nil {
+		return ValidationResult{Error: err.Error()}
+	}
+	order := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		order = append(order, node.ID)
+	}
+	return ValidationResult{Valid: true, Order: order}
+}
+
+func readDefinition(r *http.Request) (*pipeline.Definition, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxGraphSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, fmt.Errorf("the pipeline is larger than %d bytes", maxGraphSize)
+		}
+		return nil, fmt.Errorf("failed to read the pipeline: %w", err)
+	}
+	return pipeline.ParseDefinition(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,289 @@
+package editor
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+const validGraph = `{
+  "name": "scope-only",
+  "nodes": [
+    {"id": "scope", "operator": "scope", "position": {"x": 10, "y": 20}},
+    {"id": "discovery", "operator": "discovery"}
+  ],
+  "edges": [{"from": "scope.surface", "to": "discovery.surface"}]
+}`
+
+func testServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	config := pipeline.DefaultConfig()
+	dir := t.TempDir()
+	webFolder := filepath.Join(dir, "dist")
+	if err := os.Mkdir(webFolder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(webFolder, "index.html"), []byte("<html>editor</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pipelinePath := filepath.Join(dir, "pipeline.yaml")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return httptestServer(t, New(logger, &config, pipelinePath, webFolder, "")), pipelinePath
+}
+
+func httptestServer(t *testing.T, s *Server) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func do(t *testing.T, method string, url string, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestOperatorsCatalog(t *testing.T) {
+	server, _ := testServer(t)
+	status, body := do(t, http.MethodGet, server.URL+"/api/operators", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", status, body)
+	}
+
+	var catalog []OperatorInfo
+	if err := json.Unmarshal([]byte(body), &catalog); err != nil {
+		t.Fatalf("Failed to decode the catalog: %v", err)
+	}
+	if len(catalog) != len(pipeline.Operators()) {
+		t.Fatalf("Expected %d operators, got %d", len(pipeline.Operators()), len(catalog))
+	}
+	for _, op := range catalog {
+		if op.Name == "merge" {
+			if len(op.Inputs) != 2 || op.Inputs[0].Type != pipeline.TypeSurface || len(op.Config) != 1 {
+				t.Errorf("Unexpected description of merge: %+v", op)
+			}
+			return
+		}
+	}
+	t.Errorf("merge not found in the catalog")
+}
+
+func TestValidatePipeline(t *testing.T) {
+	server, _ := testServer(t)
+	tests := []struct {
+		name           string
+		graph          string
+		expectedStatus int
+		errContains    string
+	}{
+		{"valid", validGraph, http.StatusOK, ""},
+		{"malformed", `{"nodes": [`, http.StatusBadRequest, "Invalid Syntax"},
+		{"no_discovery", `{"nodes": [{"id": "scope", "operator": "scope"}]}`, http.StatusUnprocessableEntity, "exactly one discovery node"},
+		{
+			name: "type_mismatch",
+			graph: `{"nodes": [{"id": "scope", "operator": "scope"}, {"id": "resolve", "operator": "resolve"}],
+			 "edges": [{"from": "scope.surface", "to": "resolve.domains"}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			errContains:    "cannot connect an output of type surface to an input of type domains",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, http.MethodPost, server.URL+"/api/pipeline/validate", tt.graph)
+			if status != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, status, body)
+			}
+			var result ValidationResult
+			if err := json.Unmarshal([]byte(body), &result); err != nil {
+				t.Fatalf("Failed to decode the result: %v", err)
+			}
+			if result.Valid != (tt.errContains == "") {
+				t.Errorf("Unexpected result: %+v", result)
+			}
+			if !strings.Contains(result.Error, tt.errContains) {
+				t.Errorf("Error message doesn't contain %q: %s", tt.errContains, result.Error)
+			}
+		})
+	}
+}
+
+func TestSaveAndLoadPipeline(t *testing.T) {
+	server, pipelinePath := testServer(t)
+
+	// without a pipeline file, the built-in pipeline is the one asm runs
+	status, body := do(t, http.MethodGet, server.URL+"/api/pipeline", "")
+	if status != http.StatusOK || !strings.Contains(body, `"name":"default"`) {
+		t.Fatalf("Expected the default pipeline, got %d: %s", status, body)
+	}
+
+	status, body = do(t, http.MethodPut, server.URL+"/api/pipeline", `{"nodes": [{"id": "scope", "operator": "scope"}]}`)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected an invalid pipeline to be rejected, got %d: %s", status, body)
+	}
+	if _, err := os.Stat(pipelinePath); !os.IsNotExist(err) {
+		t.Fatalf("An invalid pipeline was written")
+	}
+
+	status, body = do(t, http.MethodPut, server.URL+"/api/pipeline", validGraph)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", status, body)
+	}
+
+	// the saved file must be runnable by asm
+	data, err := os.ReadFile(pipelinePath)
+	if err != nil {
+		t.Fatalf("Failed to read the saved pipeline: %v", err)
+	}
+	definition, err := pipeline.ParseDefinition(data)
+	if err != nil {
+		t.Fatalf("Failed to parse the saved pipeline: %v", err)
+	}
+	config := pipeline.DefaultConfig()
+	if _, err := pipeline.CompileSurfaceDiscovery(definition, &config); err != nil {
+		t.Fatalf("The saved pipeline does not compile: %v", err)
+	}
+	if definition.Nodes[0].Position == nil || definition.Nodes[0].Position.X != 10 {
+		t.Errorf("The node positions were not saved: %+v", definition.Nodes[0])
+	}
+	if !strings.HasPrefix(string(data), pipelineHeader+"\n") {
+		t.Errorf("Expected the saved pipeline to start with the editor header, got: %s", data)
+	}
+	// the file is replaced through a temporary file, that must not be left behind
+	entries, err := os.ReadDir(filepath.Dir(pipelinePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Temporary file left behind: %s", entry.Name())
+		}
+	}
+
+	status, body = do(t, http.MethodGet, server.URL+"/api/pipeline", "")
+	if status != http.StatusOK || !strings.Contains(body, `"name":"scope-only"`) {
+		t.Fatalf("Expected the saved pipeline, got %d: %s", status, body)
+	}
+}
+
+func TestCrossOriginRequests(t *testing.T) {
+	server, pipelinePath := testServer(t)
+	tests := []struct {
+		name           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{"same origin", map[string]string{"Content-Type": "application/json", "Origin": server.URL, "Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{"no browser headers", map[string]string{"Content-Type": "application/json; charset=utf-8"}, http.StatusOK},
+		{"plain text", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"form", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusUnsupportedMediaType},
+		{"other origin", map[string]string{"Content-Type": "application/json", "Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"cross site", map[string]string{"Content-Type": "application/json", "Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"localhost", map[string]string{"Content-Type": "application/json", "Host": "localhost"}, http.StatusOK},
+		// after a dns rebinding, the page of another site is same-origin with the editor
+		{"rebound host", map[string]string{"Content-Type": "application/json", "Host": "evil.example.com", "Origin": "http://evil.example.com", "Sec-Fetch-Site": "same-origin"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(pipelinePath)
+			req, err := http.NewRequest(http.MethodPut, server.URL+"/api/pipeline", strings.NewReader(validGraph))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if host := tt.headers["Host"]; host != "" {
+				req.Host = host
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if _, err := os.Stat(pipelinePath); (err == nil) != (tt.expectedStatus == http.StatusOK) {
+				t.Errorf("Unexpected state of the pipeline file: %v", err)
+			}
+		})
+	}
+}
+
+func TestAllowedHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		address  string
+		expected bool
+	}{
+		{"127.0.0.1:8080", "127.0.0.1:8080", true},
+		{"localhost:8080", "127.0.0.1:8080", true},
+		{"editor.localhost", "127.0.0.1:8080", true},
+		{"[::1]:8080", "127.0.0.1:8080", true},
+		{"127.0.0.2", "", true},
+		{"asm.internal:8080", "asm.internal:8080", true},
+		{"ASM.internal", "asm.internal:8080", true},
+		{"evil.example.com:8080", "127.0.0.1:8080", false},
+		{"evil.example.com", "0.0.0.0:8080", false},
+		{"192.168.1.10:8080", ":8080", false},
+		{"", "127.0.0.1:8080", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := allowedHost(tt.host, tt.address); got != tt.expected {
+				t.Errorf("Expected %v for %q on %q, got %v", tt.expected, tt.host, tt.address, got)
+			}
+		})
+	}
+}
+
+func TestServeWebApp(t *testing.T) {
+	server, _ := testServer(t)
+	status, body := do(t, http.MethodGet, server.URL+"/", "")
+	if status != http.StatusOK || !strings.Contains(body, "editor") {
+		t.Fatalf("Expected the web app, got %d: %s", status, body)
+	}
+
+	// reads are not allowed to a rebound host either
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/pipeline", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "evil.example.com"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", resp.StatusCode)
+	}
+}
@@ -14,13 +14,33 @@ type EnvConfig struct {
 	ConfigFolder string `env:"CONFIG_FOLDER"`
 	DataFolder   string `env:"DATA_FOLDER"`
 	SecretTest   string `env:"SECRET_TEST" sensitive:"true"`
+	// WebFolder, EditorAddress and EditorDebug are only used by the pipeline editor.
+	// When EditorDebug is "true", the editor can start actual scans
+	WebFolder     string `env:"WEB_FOLDER"`
+	EditorAddress string `env:"EDITOR_ADDRESS"`
+	EditorDebug   string `env:"EDITOR_DEBUG"`
+	// The GitLab issues of the detected issues are only managed when both
+	// GitlabProject and GitlabToken are set. In a CI job, GitlabURL and GitlabProject
+	// can be set to the predefined CI_SERVER_URL and CI_PROJECT_ID.
+	// GitlabIssues is either "per-issue" or "digest"
+	GitlabURL     string `env:"GITLAB_URL"`
+	GitlabProject string `env:"GITLAB_PROJECT"`
+	GitlabToken   string `env:"GITLAB_TOKEN" sensitive:"true"`
+	GitlabIssues  string `env:"GITLAB_ISSUES"`
 }
 
 func defaultEnvConfig() EnvConfig {
 	return EnvConfig{
-		ConfigFolder: "./test/config", //TODO: use ./ as default
-		DataFolder:   "./test/data",   //TODO: use ./data as default
-		SecretTest:   "",
+		ConfigFolder:  "./test/config", //TODO: use ./ as default
+		DataFolder:    "./test/data",   //TODO: use ./data as default
+		SecretTest:    "",
+		WebFolder:     "./web/dist",
+		EditorAddress: "127.0.0.1:8080",
+		EditorDebug:   "false",
+		GitlabURL:     "https://gitlab.com",
+		GitlabProject: "",
+		GitlabToken:   "",
+		GitlabIssues:  "per-issue",
 	}
 }
 
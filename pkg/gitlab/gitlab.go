@@ -0,0 +1,176 @@
+// Package gitlab notifies reviewers of the detected issues through the issues of
+// a GitLab project, using the GitLab REST API.
+// The GitLab issues it manages carry a label and a hidden marker in their
+// description, so that each run can find and update the ones opened by the
+// previous runs, and close them when the findings are resolved or ignored.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ModePerIssue opens a GitLab issue for every finding
+	ModePerIssue = "per-issue"
+	// ModeDigest keeps a single GitLab issue, listing all the active findings
+	ModeDigest = "digest"
+
+	// Label marks the GitLab issues managed by tinyasm
+	Label = "tinyasm"
+
+	// maxErrorSize is how much of the body of a failed API response is reported
+	maxErrorSize = 1 << 10
+	// pageSize is the number of issues requested per page, the maximum allowed by GitLab
+	pageSize = 100
+)
+
+// Notifier opens, updates and closes the GitLab issues of a project
+type Notifier struct {
+	logger *slog.Logger
+	// projectURL is the API url of the project, all the requests are relative to it
+	projectURL string
+	token      string
+	mode       string
+	client     *http.Client
+}
+
+// New initializes a Notifier for project, that can be either the numeric id or the
+// full path of a project on the GitLab instance at gitlabURL.
+// token must be an access token with the api scope on the project.
+func New(logger *slog.Logger, gitlabURL string, project string, token string, mode string) (*Notifier, error) {
+	base, err := url.Parse(gitlabURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid gitlab url %q", gitlabURL)
+	}
+	if project == "" {
+		return nil, fmt.Errorf("the gitlab project is not set")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("the gitlab token is not set")
+	}
+	if mode != ModePerIssue && mode != ModeDigest {
+		return nil, fmt.Errorf("invalid gitlab issues mode %q: must be %s or %s", mode, ModePerIssue, ModeDigest)
+	}
+
+	return &Notifier{
+		logger:     logger,
+		projectURL: strings.TrimSuffix(base.String(), "/") + "/api/v4/projects/" + url.PathEscape(project),
+		token:      token,
+		mode:       mode,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// issue is a GitLab issue, as returned by the API
+type issue struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// State is either opened or closed
+	State string `json:"state"`
+}
+
+func (i *issue) opened() bool {
+	return i.State == "opened"
+}
+
+// listIssues returns all the issues of the project with Label, open or closed
+func (n *Notifier) listIssues(ctx context.Context) ([]issue, error) {
+	var all []issue
+	page := "1"
+	for page != "" {
+		query := url.Values{
+			"labels":   {Label},
+			"state":    {"all"},
+			"per_page": {strconv.Itoa(pageSize)},
+			"page":     {page},
+		}
+		var issues []issue
+		header, err := n.do(ctx, http.MethodGet, "/issues?"+query.Encode(), nil, &issues)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, issues...)
+		page = header.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+func (n *Notifier) createIssue(ctx context.Context, title string, description string) error {
+	body := map[string]string{
+		"title":       title,
+		"description": description,
+		"labels":      Label,
+	}
+	_, err := n.do(ctx, http.MethodPost, "/issues", body, nil)
+	return err
+}
+
+// updateIssue edits the given fields of an issue,
+// for example the description, or the state_event that opens or closes it
+func (n *Notifier) updateIssue(ctx context.Context, iid int, fields map[string]string) error {
+	_, err := n.do(ctx, http.MethodPut, fmt.Sprintf("/issues/%d", iid), fields, nil)
+	return err
+}
+
+func (n *Notifier) addNote(ctx context.Context, iid int, note string) error {
+	_, err := n.do(ctx, http.MethodPost, fmt.Sprintf("/issues/%d/notes", iid), map[string]string{"body": note}, nil)
+	return err
+}
+
+// closeIssue comments an issue with the reason why it is being closed, and closes it
+func (n *Notifier) closeIssue(ctx context.Context, iid int, reason string) error {
+	if err := n.addNote(ctx, iid, reason); err != nil {
+		return err
+	}
+	return n.updateIssue(ctx, iid, map[string]string{"state_event": "close"})
+}
+
+// do sends a request to the project API. body is encoded as json, and the
+// response is decoded into out, when they are not nil
+func (n *Notifier) do(ctx context.Context, method string, path string, body any, out any) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the gitlab request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, n.projectURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the gitlab request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", n.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gitlab request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+		return nil, fmt.Errorf("gitlab request %s %s failed: %s: %s", method, path, resp.Status, bytes.TrimSpace(message))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode the gitlab response to %s %s: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
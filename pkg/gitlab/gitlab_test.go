@@ -0,0 +1,334 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+const (
+	testProject = "group/project"
+	testToken   = "secret"
+)
+
+// fakeIssue is an issue stored by fakeGitLab
+type fakeIssue struct {
+	issue
+	Labels []string `json:"labels"`
+	Notes  []string `json:"-"`
+}
+
+// fakeGitLab is a stand-in for the subset of the GitLab API used by the notifier
+type fakeGitLab struct {
+	mu     sync.Mutex
+	issues []*fakeIssue
+	// writes are the requests that changed an issue, in the format "METHOD path"
+	writes []string
+	// perPage is small, so that the listing is paginated
+	perPage int
+}
+
+func newFakeGitLab(t *testing.T) (*fakeGitLab, *Notifier) {
+	return newFakeGitLabMode(t, ModePerIssue)
+}
+
+func newFakeGitLabMode(t *testing.T, mode string) (*fakeGitLab, *Notifier) {
+	t.Helper()
+	f := &fakeGitLab{perPage: 2}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/projects/{project}/issues", f.handleList)
+	mux.HandleFunc("POST /api/v4/projects/{project}/issues", f.handleCreate)
+	mux.HandleFunc("PUT /api/v4/projects/{project}/issues/{iid}", f.handleUpdate)
+	mux.HandleFunc("POST /api/v4/projects/{project}/issues/{iid}/notes", f.handleNote)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != testToken {
+			http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	n, err := New(logger, server.URL, testProject, testToken, mode)
+	if err != nil {
+		t.Fatalf("Failed to create the notifier: %v", err)
+	}
+	return f, n
+}
+
+func (f *fakeGitLab) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("project") != testProject || r.URL.Query().Get("state") != "all" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	var labeled []*fakeIssue
+	for _, i := range f.issues {
+		if slices.Contains(i.Labels, r.URL.Query().Get("labels")) {
+			labeled = append(labeled, i)
+		}
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	start := min((page-1)*f.perPage, len(labeled))
+	end := min(start+f.perPage, len(labeled))
+	if end < len(labeled) {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	_ = json.NewEncoder(w).Encode(labeled[start:end])
+}
+
+func (f *fakeGitLab) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var fields map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil || fields["title"] == "" {
+		http.Error(w, "invalid issue", http.StatusBadRequest)
+		return
+	}
+	f.writes = append(f.writes, "POST /issues")
+	f.issues = append(f.issues, &fakeIssue{
+		issue: issue{
+			IID:         len(f.issues) + 1,
+			Title:       fields["title"],
+			Description: fields["description"],
+			State:       "opened",
+		},
+		Labels: strings.Split(fields["labels"], ","),
+	})
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(f.issues[len(f.issues)-1])
+}
+
+func (f *fakeGitLab) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	i := f.find(w, r)
+	if i == nil {
+		return
+	}
+	var fields map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		http.Error(w, "invalid fields", http.StatusBadRequest)
+		return
+	}
+	f.writes = append(f.writes, "PUT /issues/"+r.PathValue("iid"))
+	if description, ok := fields["description"]; ok {
+		i.Description = description
+	}
+	switch fields["state_event"] {
+	case "close":
+		i.State = "closed"
+	case "reopen":
+		i.State = "opened"
+	}
+	_ = json.NewEncoder(w).Encode(i)
+}
+
+func (f *fakeGitLab) handleNote(w http.ResponseWriter, r *http.Request) {
+	i := f.find(w, r)
+	if i == nil {
+		return
+	}
+	var fields map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil || fields["body"] == "" {
+		http.Error(w, "invalid note", http.StatusBadRequest)
+		return
+	}
+	f.writes = append(f.writes, "POST /issues/"+r.PathValue("iid")+"/notes")
+	i.Notes = append(i.Notes, fields["body"])
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte("{}"))
+}
+
+func (f *fakeGitLab) find(w http.ResponseWriter, r *http.Request) *fakeIssue {
+	iid, _ := strconv.Atoi(r.PathValue("iid"))
+	if r.PathValue("project") != testProject || iid < 1 || iid > len(f.issues) {
+		http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+		return nil
+	}
+	return f.issues[iid-1]
+}
+
+// takeWrites returns the writes received since the last call
+func (f *fakeGitLab) takeWrites() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writes := f.writes
+	f.writes = nil
+	return writes
+}
+
+// runs simulates consecutive runs, passing the active issues of each one to the next
+type runs struct {
+	previous []issues.Issue
+	now      time.Time
+}
+
+func (r *runs) next(current []issues.Issue, ignore []string) issues.Result {
+	r.now = r.now.Add(24 * time.Hour)
+	result := issues.Classify(r.previous, current, ignore, r.now)
+	r.previous = result.Active()
+	return result
+}
+
+func TestNew(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tests := []struct {
+		name        string
+		gitlabURL   string
+		project     string
+		token       string
+		mode        string
+		errContains string
+	}{
+		{"valid", "https://gitlab.example.com/", "group/project", "secret", ModeDigest, ""},
+		{"invalid url", "gitlab.example.com", "group/project", "secret", ModeDigest, "invalid gitlab url"},
+		{"missing project", "https://gitlab.example.com", "", "secret", ModeDigest, "project is not set"},
+		{"missing token", "https://gitlab.example.com", "group/project", "", ModeDigest, "token is not set"},
+		{"invalid mode", "https://gitlab.example.com", "group/project", "secret", "weekly", "invalid gitlab issues mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := New(logger, tt.gitlabURL, tt.project, tt.token, tt.mode)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := "https://gitlab.example.com/api/v4/projects/group%2Fproject"
+			if n.projectURL != expected {
+				t.Errorf("projectURL = %q, want %q", n.projectURL, expected)
+			}
+		})
+	}
+}
+
+func TestNotifyPerIssue(t *testing.T) {
+	f, n := newFakeGitLab(t)
+	ctx := context.Background()
+	a := issues.New("takeover", "a.example.com", "", "dangling CNAME")
+	b := issues.New("takeover", "b.example.com", "", "dangling CNAME")
+	c := issues.New("takeover", "c.example.com", "", "dangling CNAME")
+
+	// an issue with the label, that was not opened by the notifier
+	f.issues = append(f.issues, &fakeIssue{
+		issue:  issue{IID: 1, Title: "Tune the scan", Description: "by hand", State: "opened"},
+		Labels: []string{Label},
+	})
+
+	r := &runs{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := n.Notify(ctx, r.next([]issues.Issue{a, b}, nil)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if writes := f.takeWrites(); len(writes) != 2 {
+		t.Fatalf("Expected 2 issues to be opened, got %v", writes)
+	}
+	if f.issues[1].Title != "takeover on a.example.com" || !strings.Contains(f.issues[1].Description, a.IgnoreLine()) {
+		t.Errorf("Unexpected issue: %+v", f.issues[1])
+	}
+
+	// detecting the same issues again does not touch GitLab
+	if err := n.Notify(ctx, r.next([]issues.Issue{a, b}, nil)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if writes := f.takeWrites(); len(writes) != 0 {
+		t.Fatalf("Expected no writes, got %v", writes)
+	}
+
+	// a is resolved, b is ignored, c is new
+	if err := n.Notify(ctx, r.next([]issues.Issue{b, c}, []string{b.ID})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f.takeWrites()
+	expectedStates := []string{"opened", "closed", "closed", "opened"}
+	for i, state := range expectedStates {
+		if f.issues[i].State != state {
+			t.Errorf("Issue %d: state = %s, want %s", i+1, f.issues[i].State, state)
+		}
+	}
+	if !slices.Equal(f.issues[1].Notes, []string{resolvedReason}) || !slices.Equal(f.issues[2].Notes, []string{ignoredReason}) {
+		t.Errorf("Unexpected closing notes: %v, %v", f.issues[1].Notes, f.issues[2].Notes)
+	}
+
+	// a is detected again, and its issue reopened
+	if err := n.Notify(ctx, r.next([]issues.Issue{a, b, c}, []string{b.ID})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if writes := f.takeWrites(); !slices.Equal(writes, []string{"PUT /issues/2"}) {
+		t.Fatalf("Expected the issue of a to be reopened, got %v", writes)
+	}
+	if f.issues[1].State != "opened" || len(f.issues) != 4 {
+		t.Errorf("The issue of a was not reopened: %+v", f.issues[1])
+	}
+}
+
+func TestNotifyDigest(t *testing.T) {
+	f, n := newFakeGitLabMode(t, ModeDigest)
+	ctx := context.Background()
+	a := issues.New("takeover", "a.example.com", "", "dangling CNAME")
+	b := issues.New("takeover", "b.example.com", "", "dangling CNAME")
+
+	r := &runs{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := n.Notify(ctx, r.next([]issues.Issue{a}, nil)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(f.issues) != 1 || f.issues[0].Title != digestTitle || !strings.Contains(f.issues[0].Description, a.IgnoreLine()) {
+		t.Fatalf("Expected the digest issue to be opened, got %+v", f.issues)
+	}
+	f.takeWrites()
+
+	if err := n.Notify(ctx, r.next([]issues.Issue{a}, nil)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if writes := f.takeWrites(); len(writes) != 0 {
+		t.Fatalf("Expected no writes, got %v", writes)
+	}
+
+	// a new issue updates the digest, and notifies its subscribers
+	if err := n.Notify(ctx, r.next([]issues.Issue{a, b}, nil)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if writes := f.takeWrites(); !slices.Equal(writes, []string{"PUT /issues/1", "POST /issues/1/notes"}) {
+		t.Fatalf("Expected the digest to be updated and commented, got %v", writes)
+	}
+	if !strings.Contains(f.issues[0].Description, b.IgnoreLine()) || !strings.Contains(f.issues[0].Notes[0], "b.example.com") {
+		t.Errorf("Unexpected digest: %+v", f.issues[0])
+	}
+
+	// the digest is closed when no issue is left, and reopened by the next one
+	if err := n.Notify(ctx, r.next([]issues.Issue{a, b}, []string{a.ID, b.ID})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.issues[0].State != "closed" || f.issues[0].Notes[len(f.issues[0].Notes)-1] != digestReason {
+		t.Fatalf("Expected the digest to be closed, got %+v", f.issues[0])
+	}
+	if err := n.Notify(ctx, r.next([]issues.Issue{a}, nil)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(f.issues) != 1 || f.issues[0].State != "opened" {
+		t.Fatalf("Expected the digest to be reopened, got %+v", f.issues)
+	}
+}
+
+func TestNotifyError(t *testing.T) {
+	_, n := newFakeGitLab(t)
+	n.token = "wrong"
+	err := n.Notify(context.Background(), issues.Result{})
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Fatalf("Expected an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+const (
+	// maxTitleLength is the longest title GitLab accepts
+	maxTitleLength = 255
+
+	digestTitle  = "TinyASM: detected issues"
+	digestMarker = "<!-- tinyasm-digest -->"
+
+	resolvedReason = "This issue was not detected in the last run, closing it."
+	ignoredReason  = "This issue was added to ignore-issues.yaml, closing it."
+	digestReason   = "All the issues were resolved or ignored, closing the digest."
+)
+
+// issueMarker matches the marker that links a GitLab issue to the ID of a finding
+var issueMarker = regexp.MustCompile(`<!-- tinyasm-issue: (".*") -->`)
+
+// Notify brings the GitLab issues of the project in line with the issues of a run:
+// new findings are reported, and the ones that were resolved or ignored are closed.
+// A failure on a single GitLab issue does not stop the others from being updated,
+// all the errors are returned together.
+func (n *Notifier) Notify(ctx context.Context, result issues.Result) error {
+	existing, err := n.listIssues(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the gitlab issues: %w", err)
+	}
+	if n.mode == ModeDigest {
+		return n.notifyDigest(ctx, result, existing)
+	}
+	return n.notifyPerIssue(ctx, result, existing)
+}
+
+// notifyPerIssue keeps a GitLab issue for every finding.
+// The GitLab issues closed by a reviewer are reopened only when the finding is
+// detected again after being resolved, so that closing one by hand silences it
+// until then.
+func (n *Notifier) notifyPerIssue(ctx context.Context, result issues.Result, existing []issue) error {
+	byID := make(map[string]issue)
+	for _, gitlabIssue := range existing {
+		match := issueMarker.FindStringSubmatch(gitlabIssue.Description)
+		if match == nil {
+			continue
+		}
+		id, err := strconv.Unquote(match[1])
+		if err != nil {
+			continue
+		}
+		// when the same finding has more than one issue, the open one wins
+		if previous, duplicate := byID[id]; duplicate && previous.opened() {
+			continue
+		}
+		byID[id] = gitlabIssue
+	}
+
+	var errs []error
+	opened, updated, closed := 0, 0, 0
+	for _, list := range []struct {
+		issues []issues.Issue
+		reopen bool
+	}{
+		{result.New, true},
+		{result.Old, false},
+	} {
+		for _, finding := range list.issues {
+			description := issueDescription(finding)
+			gitlabIssue, exists := byID[finding.ID]
+			var err error
+			switch {
+			case !exists:
+				opened++
+				err = n.createIssue(ctx, issueTitle(finding), description)
+			case !gitlabIssue.opened() && list.reopen:
+				opened++
+				err = n.updateIssue(ctx, gitlabIssue.IID, map[string]string{
+					"description": description,
+					"state_event": "reopen",
+				})
+			case gitlabIssue.opened() && !sameDescription(gitlabIssue.Description, description):
+				updated++
+				err = n.updateIssue(ctx, gitlabIssue.IID, map[string]string{"description": description})
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to report %s: %w", finding.ID, err))
+			}
+		}
+	}
+
+	for _, list := range []struct {
+		issues []issues.Issue
+		reason string
+	}{
+		{result.Resolved, resolvedReason},
+		{result.Ignored, ignoredReason},
+	} {
+		for _, finding := range list.issues {
+			gitlabIssue, exists := byID[finding.ID]
+			if !exists || !gitlabIssue.opened() {
+				continue
+			}
+			closed++
+			if err := n.closeIssue(ctx, gitlabIssue.IID, list.reason); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close the issue of %s: %w", finding.ID, err))
+			}
+		}
+	}
+
+	n.logger.Info("GitLab issues updated", "opened", opened, "updated", updated, "closed", closed)
+	return errors.Join(errs...)
+}
+
+// notifyDigest keeps a single GitLab issue listing all the active findings.
+// The issue is commented when new findings are detected, so that its subscribers
+// are notified, and closed when no finding is left.
+func (n *Notifier) notifyDigest(ctx context.Context, result issues.Result, existing []issue) error {
+	var digest *issue
+	for i := range existing {
+		if !strings.Contains(existing[i].Description, digestMarker) {
+			continue
+		}
+		if digest == nil || (!digest.opened() && existing[i].opened()) {
+			digest = &existing[i]
+		}
+	}
+
+	active := result.Active()
+	if len(active) == 0 {
+		if digest == nil || !digest.opened() {
+			return nil
+		}
+		n.logger.Info("Closing the GitLab digest issue", "iid", digest.IID)
+		return n.closeIssue(ctx, digest.IID, digestReason)
+	}
+
+	description := digestDescription(active)
+	if digest == nil {
+		n.logger.Info("Opening the GitLab digest issue", "issues", len(active))
+		return n.createIssue(ctx, digestTitle, description)
+	}
+
+	fields := make(map[string]string)
+	if !sameDescription(digest.Description, description) {
+		fields["description"] = description
+	}
+	if !digest.opened() {
+		fields["state_event"] = "reopen"
+	}
+	if len(fields) > 0 {
+		n.logger.Info("Updating the GitLab digest issue", "iid", digest.IID, "issues", len(active))
+		if err := n.updateIssue(ctx, digest.IID, fields); err != nil {
+			return err
+		}
+	}
+
+	if len(result.New) == 0 && len(result.Resolved) == 0 {
+		return nil
+	}
+	note := fmt.Sprintf("This run detected %d new issues, and %d issues were resolved.", len(result.New), len(result.Resolved))
+	for _, finding := range result.New {
+		note += "\n- " + findingLine(finding)
+	}
+	return n.addNote(ctx, digest.IID, note)
+}
+
+// sameDescription compares descriptions ignoring the leading and trailing
+// whitespace, that GitLab does not always preserve
+func sameDescription(a string, b string) bool {
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
+
+func issueTitle(finding issues.Issue) string {
+	title := fmt.Sprintf("%s on %s", finding.Kind, finding.Asset)
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength-3]) + "..."
+	}
+	return title
+}
+
+// issueDescription is the description of the GitLab issue of a finding.
+// It only holds the values that are stable across runs, so that it does not
+// need to be edited on every run
+func issueDescription(finding issues.Issue) string {
+	var b strings.Builder
+	b.WriteString(findingLine(finding) + "\n\n")
+	b.WriteString(ignoreHint(finding) + "\n\n")
+	fmt.Fprintf(&b, "<!-- tinyasm-issue: %s -->\n", strconv.Quote(finding.ID))
+	return b.String()
+}
+
+func digestDescription(active []issues.Issue) string {
+	var b strings.Builder
+	b.WriteString("The issues detected by TinyASM, that were not resolved or ignored yet.\n\n")
+	for _, finding := range active {
+		b.WriteString("- " + findingLine(finding) + "\n")
+		for _, line := range strings.Split(ignoreHint(finding), "\n") {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n" + digestMarker + "\n")
+	return b.String()
+}
+
+func findingLine(finding issues.Issue) string {
+	return fmt.Sprintf("**%s** on `%s`: %s (first seen %s)",
+		finding.Kind, finding.Asset, finding.Description, finding.FirstSeen.UTC().Format(time.DateOnly))
+}
+
+func ignoreHint(finding issues.Issue) string {
+	return "If false positive, add this line to ignore-issues.yaml:\n```yaml\n" + finding.IgnoreLine() + "\n```"
+}
@@ -0,0 +1,104 @@
+// Package iprange parses the IPs, CIDRs and IP ranges accepted in the scope
+// and in the exclusions, into the prefixes that cover their addresses.
+// The configuration files are validated with the same parser the pipeline uses.
+package iprange
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Parse parses an IP, a CIDR, or an IP range in the format a.b.c.d-a.b.c.e
+// into the list of prefixes that covers exactly the same addresses.
+func Parse(s string) ([]netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+
+	if start, end, isRange := strings.Cut(s, "-"); isRange {
+		startAddr, err := netip.ParseAddr(strings.TrimSpace(start))
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range '%s': %w", s, err)
+		}
+		endAddr, err := netip.ParseAddr(strings.TrimSpace(end))
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range '%s': %w", s, err)
+		}
+		startAddr, endAddr = startAddr.Unmap(), endAddr.Unmap()
+		if startAddr.Is4() != endAddr.Is4() {
+			return nil, fmt.Errorf("invalid IP range '%s': mixed IPv4 and IPv6 addresses", s)
+		}
+		if startAddr.Compare(endAddr) > 0 {
+			return nil, fmt.Errorf("invalid IP range '%s': the first address is greater than the last", s)
+		}
+		return rangeToPrefixes(startAddr, endAddr), nil
+	}
+
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR notation '%s': %w", s, err)
+		}
+		return []netip.Prefix{prefix.Masked()}, nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address '%s': %w", s, err)
+	}
+	addr = addr.Unmap()
+	return []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+}
+
+// rangeToPrefixes returns the smallest list of prefixes that covers
+// all the addresses from start to end, both included.
+func rangeToPrefixes(start netip.Addr, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		// find the largest prefix that starts at start, and does not go past end
+		bits := start.BitLen()
+		for bits > 0 {
+			larger := netip.PrefixFrom(start, bits-1).Masked()
+			if larger.Addr() != start || LastAddr(larger).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, prefix)
+
+		last := LastAddr(prefix)
+		if last.Compare(end) >= 0 {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// LastAddr returns the last address of a prefix
+func LastAddr(prefix netip.Prefix) netip.Addr {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+	if addr.Is4() {
+		b := addr.As4()
+		setHostBits(b[:], prefix.Bits())
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	setHostBits(b[:], prefix.Bits())
+	return netip.AddrFrom16(b)
+}
+
+func setHostBits(b []byte, bits int) {
+	for i := bits; i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+}
+
+// Format returns a prefix in CIDR notation, or as a plain address
+// if it contains a single address
+func Format(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
@@ -0,0 +1,74 @@
+package iprange
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		errContains string
+	}{
+		{name: "address", input: "192.0.2.1", expected: []string{"192.0.2.1/32"}},
+		{name: "mapped address", input: "::ffff:192.0.2.1", expected: []string{"192.0.2.1/32"}},
+		{name: "ipv6 address", input: "2001:db8::1", expected: []string{"2001:db8::1/128"}},
+		{name: "cidr", input: "192.0.2.0/24", expected: []string{"192.0.2.0/24"}},
+		{name: "unmasked cidr", input: "192.0.2.7/24", expected: []string{"192.0.2.0/24"}},
+		{name: "aligned range", input: "192.0.2.0-192.0.2.255", expected: []string{"192.0.2.0/24"}},
+		{name: "range", input: "192.168.0.10 - 192.168.0.20", expected: []string{"192.168.0.10/31", "192.168.0.12/30", "192.168.0.16/30", "192.168.0.20/32"}},
+		{name: "single address range", input: "192.0.2.1-192.0.2.1", expected: []string{"192.0.2.1/32"}},
+		{name: "empty", input: "", errContains: "invalid IP address"},
+		{name: "domain", input: "example.com", errContains: "invalid IP address"},
+		{name: "bad cidr", input: "192.0.2.0/33", errContains: "invalid CIDR notation"},
+		{name: "bad range", input: "192.0.2.1-example.com", errContains: "invalid IP range"},
+		{name: "mixed range", input: "192.0.2.1-2001:db8::1", errContains: "mixed IPv4 and IPv6"},
+		{name: "reversed range", input: "192.0.2.20-192.0.2.10", errContains: "the first address is greater than the last"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := Parse(tt.input)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Expected an error containing %q, got: %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLastAddr(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"192.0.2.0/24", "192.0.2.255"},
+		{"192.0.2.7/32", "192.0.2.7"},
+		{"10.0.0.0/8", "10.255.255.255"},
+		{"2001:db8::/64", "2001:db8::ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := LastAddr(netip.MustParsePrefix(tt.prefix)).String(); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+// Package issues contains the model of the findings reported to the user,
+// and the logic to track them across runs.
+package issues
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Issue is a finding on the discovered surface that requires the attention of a reviewer
+type Issue struct {
+	// ID is a stable identifier, that does not change across runs
+	// as long as the same issue is detected on the same asset.
+	// It is the value that must be added to ignore-issues.yaml to suppress the issue.
+	ID          string    `yaml:"id"`
+	Kind        string    `yaml:"kind"`
+	Asset       string    `yaml:"asset"`
+	Description string    `yaml:"description"`
+	FirstSeen   time.Time `yaml:"first_seen"`
+	LastSeen    time.Time `yaml:"last_seen"`
+}
+
+// New initializes an Issue of the given kind, found on asset.
+// detail is optional, and must be used to tell apart different
+// issues of the same kind found on the same asset.
+func New(kind string, asset string, detail string, description string) Issue {
+	parts := []string{kind}
+	if detail != "" {
+		parts = append(parts, detail)
+	}
+	// the asset goes last: it's the only part that can contain the separator
+	parts = append(parts, asset)
+
+	return Issue{
+		ID:          strings.Join(parts, ":"),
+		Kind:        kind,
+		Asset:       asset,
+		Description: description,
+	}
+}
+
+// IgnoreLine returns the line that must be added to the list in ignore-issues.yaml
+// to suppress this issue
+func (i *Issue) IgnoreLine() string {
+	return fmt.Sprintf("- %q", i.ID)
+}
+
+// Result is the classification of the issues detected in a run,
+// compared with the issues detected in the previous one
+type Result struct {
+	// New issues, detected for the first time in this run
+	New []Issue
+	// Old issues, detected in this run and in a previous one
+	Old []Issue
+	// Resolved issues, detected in the previous run but not in this one
+	Resolved []Issue
+	// Ignored issues, detected in this run but suppressed by the ignore list
+	Ignored []Issue
+}
+
+// Classify compares the issues detected in a run with the issues of the previous one.
+// Issues with an ID in the ignore list are set aside.
+// The issues detected in this run are marked as last seen at now.
+func Classify(previous []Issue, current []Issue, ignore []string, now time.Time) Result {
+	now = now.UTC().Truncate(time.Second)
+
+	ignoreSet := make(map[string]struct{}, len(ignore))
+	for _, id := range ignore {
+		ignoreSet[strings.TrimSpace(id)] = struct{}{}
+	}
+	previousSet := make(map[string]Issue, len(previous))
+	for _, issue := range previous {
+		previousSet[issue.ID] = issue
+	}
+
+	r := Result{
+		New:      []Issue{},
+		Old:      []Issue{},
+		Resolved: []Issue{},
+		Ignored:  []Issue{},
+	}
+	currentSet := make(map[string]struct{}, len(current))
+	for _, issue := range current {
+		if _, duplicate := currentSet[issue.ID]; duplicate {
+			continue
+		}
+		currentSet[issue.ID] = struct{}{}
+
+		issue.LastSeen = now
+		old, exists := previousSet[issue.ID]
+		if exists {
+			issue.FirstSeen = old.FirstSeen
+		} else {
+			issue.FirstSeen = now
+		}
+
+		if _, ignored := ignoreSet[issue.ID]; ignored {
+			r.Ignored = append(r.Ignored, issue)
+		} else if exists {
+			r.Old = append(r.Old, issue)
+		} else {
+			r.New = append(r.New, issue)
+		}
+	}
+	for _, issue := range previous {
+		_, detected := currentSet[issue.ID]
+		_, ignored := ignoreSet[issue.ID]
+		if !detected && !ignored {
+			r.Resolved = append(r.Resolved, issue)
+		}
+	}
+
+	for _, list := range [][]Issue{r.New, r.Old, r.Resolved, r.Ignored} {
+		Sort(list)
+	}
+	return r
+}
+
+// Active returns the issues detected in this run that were not ignored
+func (r *Result) Active() []Issue {
+	active := slices.Concat(r.New, r.Old)
+	Sort(active)
+	return active
+}
+
+func (r *Result) Summary() string {
+	return fmt.Sprintf(
+		"Issues: {New[%d], Old[%d], Resolved[%d], Ignored[%d]}",
+		len(r.New),
+		len(r.Old),
+		len(r.Resolved),
+		len(r.Ignored),
+	)
+}
+
+// Sort sorts issues by ID, in place
+func Sort(issues []Issue) {
+	slices.SortFunc(issues, func(a, b Issue) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,84 @@
+package issues
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		asset    string
+		detail   string
+		expected string
+	}{
+		{"without detail", "takeover", "a.example.com", "", "takeover:a.example.com"},
+		{"with detail", "takeover", "a.example.com", "aws-s3", "takeover:aws-s3:a.example.com"},
+		{"url asset", "new-technology", "https://example.com:8443/a", "jenkins", "new-technology:jenkins:https://example.com:8443/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := New(tt.kind, tt.asset, tt.detail, "description")
+			if issue.ID != tt.expected {
+				t.Errorf("ID = %q, want %q", issue.ID, tt.expected)
+			}
+
+			// The ignore line must be valid yaml, that parses back to the ID
+			var ignoreFile struct {
+				Ignore []string `yaml:"ignore"`
+			}
+			err := yaml.Unmarshal([]byte("ignore:\n  "+issue.IgnoreLine()+"\n"), &ignoreFile)
+			if err != nil {
+				t.Fatalf("Ignore line %q is not valid yaml: %v", issue.IgnoreLine(), err)
+			}
+			if !reflect.DeepEqual(ignoreFile.Ignore, []string{issue.ID}) {
+				t.Errorf("Ignore line parsed to %v, want [%s]", ignoreFile.Ignore, issue.ID)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	previousRun := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := previousRun.Add(24 * time.Hour)
+
+	withTimes := func(issue Issue, firstSeen, lastSeen time.Time) Issue {
+		issue.FirstSeen = firstSeen
+		issue.LastSeen = lastSeen
+		return issue
+	}
+
+	persistent := New("takeover", "a.example.com", "", "dangling CNAME")
+	resolved := New("takeover", "b.example.com", "", "dangling CNAME")
+	fresh := New("takeover", "c.example.com", "", "dangling CNAME")
+	ignored := New("takeover", "d.example.com", "", "dangling CNAME")
+
+	previous := []Issue{
+		withTimes(persistent, previousRun, previousRun),
+		withTimes(resolved, previousRun, previousRun),
+	}
+	current := []Issue{fresh, persistent, ignored, fresh}
+
+	got := Classify(previous, current, []string{ignored.ID}, now)
+	expected := Result{
+		New:      []Issue{withTimes(fresh, now, now)},
+		Old:      []Issue{withTimes(persistent, previousRun, now)},
+		Resolved: []Issue{withTimes(resolved, previousRun, previousRun)},
+		Ignored:  []Issue{withTimes(ignored, now, now)},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Classify() = %+v, want %+v", got, expected)
+	}
+
+	active := got.Active()
+	expectedActive := []Issue{withTimes(persistent, previousRun, now), withTimes(fresh, now, now)}
+	if !reflect.DeepEqual(active, expectedActive) {
+		t.Errorf("Active() = %+v, want %+v", active, expectedActive)
+	}
+
+}
@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"strings"
 
@@ -9,14 +10,17 @@ import (
 )
 
 // Alterx takes a list of domains and returns plausible alternative domains
-// generated using the alterx tool, a wordlist and a patterns list
-func Alterx(domains []string) ([]string, error) {
+// generated using the alterx tool, a wordlist and a patterns list.
+// alterx can't be interrupted: when the context is done, the context error
+// is returned right away, and the generation finishes in the background.
+// It sends no traffic.
+func Alterx(ctx context.Context, domains []string, config AlterxConfig) ([]string, error) {
 
 	// Configure alterx options
 	alterxOpts := &alterx.Options{
 		Domains: domains,
-		MaxSize: 1000,
-		Enrich:  true,
+		MaxSize: config.MaxSize,
+		Enrich:  config.Enrich,
 		//TODO: configure words and patters using an LLM
 	}
 
@@ -28,9 +32,17 @@ func Alterx(domains []string) ([]string, error) {
 
 	// Capture the output in a buffer instead of writing to stdout
 	var buffer bytes.Buffer
-	err = m.ExecuteWithWriter(&buffer)
-	if err != nil {
-		return nil, err
+	done := make(chan error, 1)
+	go func() {
+		done <- m.ExecuteWithWriter(&buffer)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 
 	// Convert buffer to string slice
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// DNSLookupFunc defines the signature for a DNS lookup functions
+type DNSLookupFunc func(domain string) ([]string, error)
+
+// FilterActive takes a list of domains and returns those with valid DNS records.
+// Domains are resolved concurrently, and only when they are not in the cache already.
+// The cache is updated with every resolution.
+func FilterActive(ctx context.Context, domains []string, cache *DNSCache, resolver *Resolver) ([]string, error) {
+	if err := resolver.ResolveAll(ctx, domains, cache); err != nil {
+		return nil, err
+	}
+
+	var validDomains []string
+	for _, domain := range domains {
+		if ips, _ := cache.Get(domain); len(ips) > 0 {
+			validDomains = append(validDomains, domain)
+		}
+	}
+	return validDomains, nil
+}
+
+// FilterWildcards takes a list of domains and returns the wildcards among them,
+// probed with the number of random names and up to the depth set in the resolver config.
+func FilterWildcards(ctx context.Context, domains []string, resolver *Resolver) ([]Wildcard, error) {
+	var resolverLookup DNSLookupFunc = func(domain string) ([]string, error) {
+		return resolver.Lookup(ctx, domain)
+	}
+	config := resolver.config
+	wildcards := filterWildcards(domains, config.WildcardProbes, config.WildcardDepth, config.Threads, resolverLookup)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return wildcards, nil
+}
+
+// wildcard discovery algorythm.
+// in a situation were the following wildcards exist:
+// *.a.example.com
+// *.test.com
+//
+// and we are given the following domains list:
+// a.example.com
+// b.example.com
+// b.a.example.com
+// c.a.example.com
+// a.test.com
+// b.test.com
+//
+// we expect to receive this:
+// a.example.com
+// a.test.com
+// b.test.com
+//
+// note how in the case of test.com the real wildcard is *.test.com, but we could not
+// detect that becasuse test.com is not in scope.
+// If we only have a subdomain in scope, the parent domain
+// is not intended to be in scope, we are not allowed to go there.
+//
+// Domains are processed from the shallowest to the deepest, so that the names covered
+// by a wildcard are never probed. Domains at the same depth are probed concurrently.
+func filterWildcards(domains []string, probes int, maxDepth int, threads int, dnsLookup DNSLookupFunc) []Wildcard {
+	// Group domains by depth (number of dots)
+	levels := make(map[int][]string)
+	seen := make(map[string]struct{})
+	for _, domain := range domains {
+		domain = normalize_domain(domain)
+		if _, err := publicsuffix.EffectiveTLDPlusOne(domain); err != nil {
+			continue
+		}
+		if _, duplicate := seen[domain]; duplicate {
+			continue
+		}
+		seen[domain] = struct{}{}
+		levels[countDots(domain)] = append(levels[countDots(domain)], domain)
+	}
+	depths := make([]int, 0, len(levels))
+	for depth := range levels {
+		depths = append(depths, depth)
+	}
+	slices.Sort(depths)
+
+	wildcards := []Wildcard{}
+	for _, depth := range depths {
+		// Skip the names covered by the known wildcards. Not all their descendants are:
+		// *.*.example.com does not cover api.example.com
+		level := slices.DeleteFunc(levels[depth], func(domain string) bool {
+			for _, parent := range wildcards {
+				if parent.Covers(domain) {
+					return true
+				}
+			}
+			return false
+		})
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		jobs := make(chan string)
+		for i := 0; i < min(threads, len(level)); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for domain := range jobs {
+					if wildcard, found := detectWildcard(domain, probes, maxDepth, dnsLookup); found {
+						mu.Lock()
+						wildcards = append(wildcards, wildcard)
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		for _, domain := range level {
+			jobs <- domain
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	slices.SortFunc(wildcards, func(a, b Wildcard) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return wildcards
+}
+
+// detectWildcard resolves random names under domain, with one random label,
+// then two, up to maxDepth. domain is a wildcard at the first depth where
+// most of the names resolve: a flaky resolver can fail a probe, or answer
+// one with a bogus address.
+// The answers are merged rather than required to be equal, since round-robin
+// wildcards answer every probe with a different subset of their addresses.
+func detectWildcard(domain string, probes int, maxDepth int, dnsLookup DNSLookupFunc) (Wildcard, bool) {
+	for depth := 1; depth <= maxDepth; depth++ {
+		resolved := 0
+		ips := []string{}
+		for range probes {
+			answer, err := dnsLookup(randomName(domain, depth))
+			if err != nil || len(answer) == 0 {
+				continue
+			}
+			resolved++
+			ips = append(ips, answer...)
+		}
+
+		if resolved*2 > probes {
+			slices.Sort(ips)
+			return Wildcard{Domain: domain, Depth: depth, IPs: slices.Compact(ips)}, true
+		}
+	}
+	return Wildcard{}, false
+}
+
+// countDots counts the number of dots in a domain name
+func countDots(domain string) int {
+	return strings.Count(domain, ".")
+}
+
+// isSubdomain checks if child is a subdomain of parent
+func isSubdomain(child, parent string) bool {
+	// Check if child ends with .parent
+	suffix := "." + parent
+	return strings.HasSuffix(child, suffix)
+}
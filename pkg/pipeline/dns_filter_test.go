@@ -1,13 +1,16 @@
 package pipeline
 
 import (
+	"errors"
 	"reflect"
 	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 )
 
-func TestDnsxFilterWildcards(t *testing.T) {
+func TestFilterWildcards(t *testing.T) {
 	tests := []struct {
 		name         string
 		inputDomains []string
@@ -190,10 +193,8 @@ func TestDnsxFilterWildcards(t *testing.T) {
 				return []string{}, nil
 			}
 
-			cache := NewDNSCache()
-
 			// Run the function with our mock lookup
-			got := dnsxFilterWildcards(tt.inputDomains, cache, mockLookup)
+			got := WildcardDomains(filterWildcards(tt.inputDomains, 3, 2, 4, mockLookup))
 
 			// Sort both slices to ensure order doesn't matter
 			sort.Strings(got)
@@ -201,7 +202,139 @@ func TestDnsxFilterWildcards(t *testing.T) {
 
 			// Compare results
 			if !reflect.DeepEqual(got, tt.expected) {
-				t.Errorf("DnsxFindWildcards() = %v, want %v", got, tt.expected)
+				t.Errorf("filterWildcards() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterWildcardsCoverage(t *testing.T) {
+	// *.*.example.com: any name at least two labels under example.com resolves
+	lookup := func(domain string) ([]string, error) {
+		if strings.HasSuffix(domain, ".example.com") && countDots(strings.TrimSuffix(domain, ".example.com")) >= 1 {
+			return []string{"192.0.2.1"}, nil
+		}
+		return []string{}, nil
+	}
+
+	tests := []struct {
+		name     string
+		domains  []string
+		expected []Wildcard
+	}{
+		{
+			name:     "deep wildcard",
+			domains:  []string{"example.com"},
+			expected: []Wildcard{{Domain: "example.com", Depth: 2, IPs: []string{"192.0.2.1"}}},
+		},
+		{
+			name:    "child not covered by the wildcard",
+			domains: []string{"example.com", "api.example.com"},
+			expected: []Wildcard{
+				{Domain: "api.example.com", Depth: 1, IPs: []string{"192.0.2.1"}},
+				{Domain: "example.com", Depth: 2, IPs: []string{"192.0.2.1"}},
+			},
+		},
+		{
+			name:     "child covered by the wildcard",
+			domains:  []string{"example.com", "a.b.example.com"},
+			expected: []Wildcard{{Domain: "example.com", Depth: 2, IPs: []string{"192.0.2.1"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterWildcards(tt.domains, 3, 2, 4, lookup)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("filterWildcards() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterWildcardsProbes(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth int
+		// answer resolves the nth probe at the given depth
+		answer   func(depth int, n int) ([]string, error)
+		expected []Wildcard
+	}{
+		{
+			name:     "round-robin wildcard",
+			maxDepth: 1,
+			answer: func(depth int, n int) ([]string, error) {
+				pool := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+				return pool[n%3 : n%3+1], nil
+			},
+			expected: []Wildcard{{Domain: "example.com", Depth: 1, IPs: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}}},
+		},
+		{
+			name:     "flaky resolver fails a probe",
+			maxDepth: 1,
+			answer: func(depth int, n int) ([]string, error) {
+				if n == 0 {
+					return nil, errors.New("timeout")
+				}
+				return []string{"192.0.2.1"}, nil
+			},
+			expected: []Wildcard{{Domain: "example.com", Depth: 1, IPs: []string{"192.0.2.1"}}},
+		},
+		{
+			name:     "flaky resolver answers a probe",
+			maxDepth: 1,
+			answer: func(depth int, n int) ([]string, error) {
+				if n == 0 {
+					return []string{"198.51.100.1"}, nil
+				}
+				return []string{}, nil
+			},
+			expected: []Wildcard{},
+		},
+		{
+			name:     "multi-level wildcard",
+			maxDepth: 2,
+			answer: func(depth int, n int) ([]string, error) {
+				if depth == 2 {
+					return []string{"192.0.2.1"}, nil
+				}
+				return []string{}, nil
+			},
+			expected: []Wildcard{{Domain: "example.com", Depth: 2, IPs: []string{"192.0.2.1"}}},
+		},
+		{
+			name:     "multi-level wildcard beyond the depth limit",
+			maxDepth: 1,
+			answer: func(depth int, n int) ([]string, error) {
+				if depth == 2 {
+					return []string{"192.0.2.1"}, nil
+				}
+				return []string{}, nil
+			},
+			expected: []Wildcard{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			probes := map[int]int{}
+			mockLookup := func(domain string) ([]string, error) {
+				if domain == "example.com" {
+					return []string{"192.0.3.1"}, nil
+				}
+				// the probes are random labels under example.com
+				depth := strings.Count(strings.TrimSuffix(domain, ".example.com"), ".") + 1
+				mu.Lock()
+				n := probes[depth]
+				probes[depth]++
+				mu.Unlock()
+				return tt.answer(depth, n)
+			}
+
+			got := filterWildcards([]string{"example.com"}, 3, tt.maxDepth, 4, mockLookup)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("filterWildcards() = %v, want %v", got, tt.expected)
 			}
 		})
 	}
@@ -1,8 +1,13 @@
 package pipeline
 
 import (
-	"fmt"
+	"context"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/httpx/runner"
@@ -10,61 +15,209 @@ import (
 
 // Result represents the result of checking a URL
 type Result struct {
-	URL        string
+	URL string
+	// Service is the host:port that answered, in the format used by Surface.Services
+	Service    string
 	StatusCode int
-	Error      error
+	// Info is only set when there is no error
+	Info HTTPInfo
+	// Simhash is the simhash of the response body
+	Simhash uint64
+	Error   error
 }
 
-// Httpx takes a Surface struct and returns a list of results
-func Httpx(surface Surface, threads int) ([]Result, error) {
+// maxBodySize is the number of bytes read from a response body.
+// httpx reads no body at all when it is not set
+const maxBodySize = 1 << 20
+
+// httpxBatchSize is the number of targets probed by a single httpx runner, for every thread.
+// httpx can't be interrupted: the targets are probed in batches, and no new batch
+// starts once the context is done. A new runner takes most of a second to start,
+// which smaller batches would pay more often
+const httpxBatchSize = 8
+
+// Httpx takes a Surface struct and returns a list of results.
+// When the context is done, it waits for the running batch of probes
+// and returns the context error, so no probe outlives the call.
+func Httpx(ctx context.Context, surface Surface, config HttpxConfig) ([]Result, error) {
 	// Combine all targets
 	var targets []string
 	targets = append(targets, surface.URLs...)
 	targets = append(targets, surface.Domains...)
 	targets = append(targets, surface.IPs...)
-	
+	targets = append(targets, surface.Services...)
+
+	var results []Result
+	batchSize := max(config.Threads, 1) * httpxBatchSize
+	for start := 0; start < len(targets); start += batchSize {
+		batch, err := httpxBatch(ctx, targets[start:min(start+batchSize, len(targets))], config)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+// httpxBatch probes targets with a single httpx runner
+func httpxBatch(ctx context.Context, targets []string, config HttpxConfig) ([]Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Create a slice to store results
 	var results []Result
 	var mu sync.Mutex
-	
+
 	// Set up httpx options
 	options := runner.Options{
 		Methods:         "GET",
 		InputTargetHost: goflags.StringSlice(targets),
-		Threads:         threads,
+		Threads:         config.Threads,
+		Timeout:         config.Timeout,
+		Retries:         config.Retries,
+		RateLimit:       config.RateLimit,
+		// collect everything HTTPInfo is made of
+		ExtractTitle:       true,
+		OutputServerHeader: true,
+		ContentLength:      true,
+		Location:           true,
+		OutputResponseTime: true,
+		TechDetect:         true,
+		TLSGrab:            true,
+		OutputCDN:          "true",
+		Hashes:             "sha256,simhash",
+		// the body is needed for its simhash, and kept in the results for the takeover fingerprints
+		MaxResponseBodySizeToRead: maxBodySize,
+		MaxResponseBodySizeToSave: maxBodySize,
+		ResponseInStdout:          true,
+		// redirects are followed only on the same host, to never leave the scope
+		FollowHostRedirects: true,
+		MaxRedirects:        10,
+		ChainInStdout:       true,
 		OnResult: func(r runner.Result) {
 			result := Result{
 				StatusCode: r.StatusCode,
 				Error:      r.Err,
 			}
-			
+
 			// If no error, add URL information
 			if r.Err == nil {
 				result.URL = r.URL
+				result.Service = resultService(r)
+				result.Info = resultInfo(r)
+				if hash, ok := r.Hashes["body_simhash"].(string); ok {
+					result.Simhash, _ = strconv.ParseUint(hash, 10, 64)
+				}
 			}
-			
+
 			// Thread-safe append to results
 			mu.Lock()
 			results = append(results, result)
 			mu.Unlock()
 		},
 	}
-	
+
 	// Validate options
 	if err := options.ValidateOptions(); err != nil {
 		return nil, err
 	}
-	
+
 	// Create and run httpx
 	httpxRunner, err := runner.New(&options)
 	if err != nil {
 		return nil, err
 	}
-	defer httpxRunner.Close()
-	
-	// Run the enumeration
-	httpxRunner.RunEnumeration()
-	
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer httpxRunner.Close()
+		httpxRunner.RunEnumeration()
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// the running batch can't be aborted, and is waited for
+		// to not leave it running. Its results are discarded
+		<-done
+		return nil, ctx.Err()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
 	return results, nil
 }
 
+// resultService returns the host:port that answered an httpx probe
+func resultService(r runner.Result) string {
+	parsed, err := url.Parse(r.URL)
+	if err != nil || parsed.Hostname() == "" {
+		return ""
+	}
+	port := r.Port
+	if port == "" {
+		port = parsed.Port()
+	}
+	if port == "" {
+		switch parsed.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return ""
+		}
+	}
+	return net.JoinHostPort(normalize_domain(parsed.Hostname()), port)
+}
+
+// resultInfo extracts the HTTPInfo of an httpx probe
+func resultInfo(r runner.Result) HTTPInfo {
+	info := HTTPInfo{
+		StatusCode:    r.StatusCode,
+		Title:         r.Title,
+		ContentLength: r.ContentLength,
+		Server:        r.WebServer,
+		CDN:           r.CDN,
+		CDNName:       r.CDNName,
+		Body:          r.ResponseBody[:min(len(r.ResponseBody), maxTakeoverBodySize)],
+	}
+
+	if hash, ok := r.Hashes["body_sha256"].(string); ok {
+		info.BodyHash = hash
+	}
+
+	for _, hop := range r.Chain {
+		if hop.Location != "" {
+			info.Redirects = append(info.Redirects, hop.Location)
+		}
+	}
+	if len(info.Redirects) == 0 && r.Location != "" {
+		info.Redirects = []string{r.Location}
+	}
+
+	if len(r.Technologies) > 0 {
+		info.Technologies = slices.Clone(r.Technologies)
+		slices.Sort(info.Technologies)
+		info.Technologies = slices.Compact(info.Technologies)
+	}
+
+	if r.TLSData != nil && r.TLSData.CertificateResponse != nil {
+		cert := r.TLSData.CertificateResponse
+		info.TLS = &TLSInfo{
+			Subject:  cert.SubjectCN,
+			SANs:     slices.Clone(cert.SubjectAN),
+			Issuer:   cert.IssuerCN,
+			NotAfter: cert.NotAfter.UTC(),
+		}
+		slices.Sort(info.TLS.SANs)
+	}
+
+	if d, err := time.ParseDuration(r.ResponseTime); err == nil {
+		info.ResponseTime = d.Round(time.Millisecond)
+	}
+
+	return info
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpxCancel(t *testing.T) {
+	// a slow server: the first batch is still running when the context is done
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	// two batches of 16 targets
+	var urls []string
+	for i := range 32 {
+		urls = append(urls, fmt.Sprintf("%s/%d", server.URL, i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := Httpx(ctx, Surface{URLs: urls}, HttpxConfig{Threads: 2, Timeout: 5, Retries: 0})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancellation error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Cancellation took %v", elapsed)
+	}
+
+	// no probe is left running once Httpx returns
+	probed := requests.Load()
+	time.Sleep(time.Second)
+	if got := requests.Load(); got != probed {
+		t.Errorf("Expected no probes after the cancellation, got %d more", got-probed)
+	}
+	if probed >= int32(len(urls)) {
+		t.Errorf("Expected the cancellation to skip some of the %d targets, got %d probes", len(urls), probed)
+	}
+}
+
+func TestHttpxBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><title>Not found</title>There isn't a GitHub Pages site here.</html>")
+	}))
+	defer server.Close()
+
+	results, err := Httpx(context.Background(), Surface{URLs: []string{server.URL}}, HttpxConfig{Threads: 1, Timeout: 5, RateLimit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("Expected one response, got: %+v", results)
+	}
+	if !strings.Contains(results[0].Info.Body, "GitHub Pages") {
+		t.Errorf("Expected the body to be kept, got: %q", results[0].Info.Body)
+	}
+}
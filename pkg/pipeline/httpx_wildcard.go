@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"math/bits"
+	"net/url"
+	"strings"
+)
+
+// Thresholds above which a response is considered different from the wildcard baseline
+const (
+	// maxSimhashDistance is the number of differing bits, out of 64,
+	// above which two bodies are not near-duplicates
+	maxSimhashDistance = 10
+	// minSizeDifference is the absolute content length difference in bytes,
+	// below which two bodies are considered of the same size
+	minSizeDifference = 64
+	// maxSizeRatio is the relative content length difference,
+	// below which two bodies are considered of the same size
+	maxSizeRatio = 0.1
+)
+
+// Fingerprint is the part of an http response used to tell
+// a catch-all wildcard response apart from a real one
+type Fingerprint struct {
+	StatusCode    int
+	ContentLength int
+	// Title has every occurrence of the host replaced by a placeholder,
+	// since catch-all pages often echo the requested host
+	Title   string
+	Simhash uint64
+}
+
+func fingerprint(result Result, host string) Fingerprint {
+	return Fingerprint{
+		StatusCode:    result.StatusCode,
+		ContentLength: result.Info.ContentLength,
+		Title:         strings.ReplaceAll(strings.ToLower(result.Info.Title), host, "{host}"),
+		Simhash:       result.Simhash,
+	}
+}
+
+// Deviates checks if a response is different from the baseline one
+func (f Fingerprint) Deviates(baseline Fingerprint) bool {
+	if f.StatusCode != baseline.StatusCode || f.Title != baseline.Title {
+		return true
+	}
+
+	sizeDifference := f.ContentLength - baseline.ContentLength
+	if sizeDifference < 0 {
+		sizeDifference = -sizeDifference
+	}
+	if sizeDifference > minSizeDifference && float64(sizeDifference) > maxSizeRatio*float64(baseline.ContentLength) {
+		return true
+	}
+
+	return bits.OnesCount64(f.Simhash^baseline.Simhash) > maxSimhashDistance
+}
+
+// HttpxWildcard probes domains that are children of wildcard domains.
+// Since they all resolve, and usually serve the same catch-all response,
+// a random name under each wildcard is probed too, as a baseline.
+// It returns the results of the candidates whose response deviates from the baseline.
+func HttpxWildcard(ctx context.Context, candidates []string, wildcards []Wildcard, config HttpxConfig) ([]Result, error) {
+	roots := WildcardDomains(wildcards)
+	depths := map[string]int{}
+	for _, wildcard := range wildcards {
+		depths[normalize_domain(wildcard.Domain)] = wildcard.Depth
+	}
+
+	// one probe for every wildcard that has candidates
+	probes := map[string]string{}
+	probed := map[string]struct{}{}
+	for _, candidate := range candidates {
+		wildcard := closestParent(normalize_domain(candidate), roots)
+		if _, exists := probed[wildcard]; exists || wildcard == "" {
+			continue
+		}
+		probed[wildcard] = struct{}{}
+		probes[randomName(wildcard, depths[wildcard])] = wildcard
+	}
+
+	targets := Surface{Domains: append([]string{}, candidates...)}
+	for probe := range probes {
+		targets.Domains = append(targets.Domains, probe)
+	}
+
+	results, err := Httpx(ctx, targets, config)
+	if err != nil {
+		return nil, err
+	}
+	return selectDeviating(results, probes, roots), nil
+}
+
+// selectDeviating splits the results into the baseline responses of the probes, keyed by wildcard,
+// scheme and port, and the responses of the candidates. It returns the candidate responses
+// that deviate from the baseline of their closest wildcard, or that have no baseline at all.
+func selectDeviating(results []Result, probes map[string]string, wildcards []string) []Result {
+	baselines := map[string]Fingerprint{}
+	var candidates []Result
+	for _, result := range results {
+		if result.Error != nil || result.URL == "" {
+			continue
+		}
+		u, err := url.Parse(result.URL)
+		if err != nil {
+			continue
+		}
+		host := normalize_domain(u.Hostname())
+		if wildcard, isProbe := probes[host]; isProbe {
+			baselines[baselineKey(wildcard, u)] = fingerprint(result, host)
+		} else {
+			candidates = append(candidates, result)
+		}
+	}
+
+	deviating := []Result{}
+	for _, result := range candidates {
+		u, _ := url.Parse(result.URL)
+		host := normalize_domain(u.Hostname())
+		wildcard := closestParent(host, wildcards)
+		if wildcard == "" {
+			continue
+		}
+		baseline, exists := baselines[baselineKey(wildcard, u)]
+		// the catch-all did not answer on this scheme and port, but the candidate did
+		if !exists || fingerprint(result, host).Deviates(baseline) {
+			deviating = append(deviating, result)
+		}
+	}
+	return deviating
+}
+
+func baselineKey(wildcard string, u *url.URL) string {
+	return u.Scheme + "://" + wildcard + ":" + u.Port()
+}
+
+// closestParent returns the deepest of the parents that domain is a subdomain of,
+// or an empty string
+func closestParent(domain string, parents []string) string {
+	closest := ""
+	for _, parent := range parents {
+		parent = normalize_domain(parent)
+		if isSubdomain(domain, parent) && len(parent) > len(closest) {
+			closest = parent
+		}
+	}
+	return closest
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFingerprintDeviates(t *testing.T) {
+	baseline := Fingerprint{StatusCode: 200, ContentLength: 1000, Title: "parked domain", Simhash: 0xF0F0F0F0F0F0F0F0}
+
+	tests := []struct {
+		name     string
+		f        Fingerprint
+		expected bool
+	}{
+		{"identical", baseline, false},
+		{"small size difference", Fingerprint{200, 1050, "parked domain", 0xF0F0F0F0F0F0F0F0}, false},
+		{"few simhash bits", Fingerprint{200, 1000, "parked domain", 0xF0F0F0F0F0F0F0FF}, false},
+		{"different status", Fingerprint{404, 1000, "parked domain", 0xF0F0F0F0F0F0F0F0}, true},
+		{"different title", Fingerprint{200, 1000, "jenkins", 0xF0F0F0F0F0F0F0F0}, true},
+		{"different size", Fingerprint{200, 5000, "parked domain", 0xF0F0F0F0F0F0F0F0}, true},
+		{"different body", Fingerprint{200, 1000, "parked domain", 0x0F0F0F0F0F0F0F0F}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.Deviates(baseline); got != tt.expected {
+				t.Errorf("Deviates() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSelectDeviating(t *testing.T) {
+	catchAll := func(url string, title string) Result {
+		return Result{URL: url, StatusCode: 200, Info: HTTPInfo{Title: title, ContentLength: 500}, Simhash: 42}
+	}
+
+	wildcards := []string{"example.com", "apps.example.com"}
+	probes := map[string]string{
+		"random1.example.com":      "example.com",
+		"random2.apps.example.com": "apps.example.com",
+	}
+	results := []Result{
+		// baselines. The catch-all of example.com echoes the host in the title
+		catchAll("https://random1.example.com", "random1.example.com is parked"),
+		catchAll("https://random2.apps.example.com", "Not found"),
+		// same catch-all response
+		catchAll("https://dev.example.com", "dev.example.com is parked"),
+		catchAll("https://old.apps.example.com", "Not found"),
+		// a real site, compared with the baseline of its closest wildcard
+		catchAll("https://jenkins.apps.example.com", "Dashboard [Jenkins]"),
+		// no catch-all response on this scheme
+		catchAll("http://legacy.example.com", "Legacy"),
+		// failed probes are never surface
+		{URL: "https://down.example.com", Error: errors.New("connection refused")},
+	}
+
+	deviating := selectDeviating(results, probes, wildcards)
+	var got []string
+	for _, result := range deviating {
+		got = append(got, result.URL)
+	}
+	expected := []string{"https://jenkins.apps.example.com", "http://legacy.example.com"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("selectDeviating() = %v, want %v", got, expected)
+	}
+}
+
+func TestFingerprintDeviatesHttpx(t *testing.T) {
+	// same status, title and size: only the bodies tell the pages apart
+	page := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "<html><head><title>Welcome</title></head><body>%s</body></html>", body)
+		}
+	}
+	catchAll := httptest.NewServer(page("This domain is parked. Buy it now from our registrar, at a great price, today only"))
+	defer catchAll.Close()
+	sameCatchAll := httptest.NewServer(page("This domain is parked. Buy it now from our registrar, at a great price, today only"))
+	defer sameCatchAll.Close()
+	jenkins := httptest.NewServer(page("Jenkins dashboard: 3 builds failing, 12 jobs queued on the agents of the CI farm"))
+	defer jenkins.Close()
+
+	results, err := Httpx(context.Background(), Surface{URLs: []string{catchAll.URL, sameCatchAll.URL, jenkins.URL}}, HttpxConfig{Threads: 1, Timeout: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fingerprints := map[string]Fingerprint{}
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("Expected no error probing %s, got: %v", result.URL, result.Error)
+		}
+		fingerprints[result.URL] = fingerprint(result, "127.0.0.1")
+	}
+	if len(fingerprints) != 3 {
+		t.Fatalf("Expected 3 responses, got: %+v", results)
+	}
+
+	baseline := fingerprints[catchAll.URL]
+	if fingerprints[sameCatchAll.URL].Deviates(baseline) {
+		t.Errorf("Expected the same body not to deviate")
+	}
+	if !fingerprints[jenkins.URL].Deviates(baseline) {
+		t.Errorf("Expected a different body to deviate: %+v, baseline %+v", fingerprints[jenkins.URL], baseline)
+	}
+}
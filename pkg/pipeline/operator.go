@@ -1,5 +1,194 @@
 package pipeline
 
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Operator is a step of a pipeline. It reads its inputs from the pipeline memory,
+// and writes its outputs back into it. Operators are stateless: everything they
+// share with the other operators of a run is in the Env.
 type Operator interface {
-	//TODO
+	// Name is the unique name of the operator, used in the pipeline definitions
+	Name() string
+	// Description is a short human-readable explanation of what the operator does
+	Description() string
+	// Inputs are the values the operator reads
+	Inputs() []Socket
+	// Outputs are the values the operator writes
+	Outputs() []Socket
+	// Config is the schema of the parameters of a node of this operator
+	Config() []ConfigField
+	// ValidateConfig checks the parameters of a node of this operator,
+	// and the sections of asmconfig.yaml it reads
+	ValidateConfig(params Params, config *Config) error
+	// Execute runs the operator. in holds a value for every input socket,
+	// except for the optional ones that are not connected.
+	// It returns a value for every output socket.
+	Execute(ctx context.Context, env *Env, params Params, in Values) (Values, error)
+}
+
+// DataType is the type of the values that flow between operators
+type DataType string
+
+const (
+	TypeSurface   DataType = "surface"   // Surface
+	TypeDomains   DataType = "domains"   // []string
+	TypeIPs       DataType = "ips"       // []string
+	TypeURLs      DataType = "urls"      // []string
+	TypeServices  DataType = "services"  // []string
+	TypePorts     DataType = "ports"     // []int
+	TypeWildcards DataType = "wildcards" // []Wildcard
+	TypeHTTP      DataType = "http"      // map[string]HTTPInfo
+	TypeDNS       DataType = "dns"       // map[string]DNSRecords
+	TypeIssues    DataType = "issues"    // []issues.Issue
+)
+
+// Socket is a typed input or output of an operator
+type Socket struct {
+	Name        string   `json:"name"`
+	Type        DataType `json:"type"`
+	Description string   `json:"description"`
+	// Optional inputs can be left unconnected
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ConfigField describes a parameter of an operator
+type ConfigField struct {
+	Name string `json:"name"`
+	// Type is one of string, int, bool
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Default     any    `json:"default,omitempty"`
+	// Values, when set, are the only values the parameter can have
+	Values []string `json:"values,omitempty"`
+}
+
+// Params are the parameters of a node, as read from a pipeline definition
+type Params map[string]any
+
+// String returns the string parameter name, or def when it is not set
+func (p Params) String(name string, def string) string {
+	if v, ok := p[name].(string); ok {
+		return v
+	}
+	return def
+}
+
+// Values are the inputs or outputs of an operator, by socket name
+type Values map[string]any
+
+// value returns the value of the socket name, or the zero value
+// of T when the socket is not connected
+func value[T any](v Values, name string) T {
+	t, _ := v[name].(T)
+	return t
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]Operator{}
+)
+
+// Register adds an operator to the registry.
+// It panics when an operator with the same name is already registered.
+func Register(op Operator) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, exists := registry[op.Name()]; exists {
+		panic(fmt.Sprintf("operator %s is already registered", op.Name()))
+	}
+	registry[op.Name()] = op
+}
+
+// LookupOperator returns the registered operator with the given name
+func LookupOperator(name string) (Operator, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	op, exists := registry[name]
+	return op, exists
+}
+
+// Operators returns all the registered operators, sorted by name
+func Operators() []Operator {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	ops := make([]Operator, 0, len(registry))
+	for _, op := range registry {
+		ops = append(ops, op)
+	}
+	slices.SortFunc(ops, func(a, b Operator) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return ops
+}
+
+// operator is the Operator implementation shared by the built-in operators
+type operator struct {
+	name        string
+	description string
+	inputs      []Socket
+	outputs     []Socket
+	config      []ConfigField
+	// validate is optional, and runs after the parameters are checked against the schema
+	validate func(params Params, config *Config) error
+	execute  func(ctx context.Context, env *Env, params Params, in Values) (Values, error)
+}
+
+func (o *operator) Name() string          { return o.name }
+func (o *operator) Description() string   { return o.description }
+func (o *operator) Inputs() []Socket      { return o.inputs }
+func (o *operator) Outputs() []Socket     { return o.outputs }
+func (o *operator) Config() []ConfigField { return o.config }
+
+func (o *operator) ValidateConfig(params Params, config *Config) error {
+	if err := validateParams(o.config, params); err != nil {
+		return err
+	}
+	if o.validate != nil {
+		return o.validate(params, config)
+	}
+	return nil
+}
+
+func (o *operator) Execute(ctx context.Context, env *Env, params Params, in Values) (Values, error) {
+	return o.execute(ctx, env, params, in)
+}
+
+// validateParams checks that every parameter is in the schema, and has the right type
+func validateParams(schema []ConfigField, params Params) error {
+	for name, v := range params {
+		i := slices.IndexFunc(schema, func(f ConfigField) bool { return f.Name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown parameter '%s'", name)
+		}
+		field := schema[i]
+
+		valid := false
+		switch field.Type {
+		case "string":
+			var s string
+			s, valid = v.(string)
+			if valid && len(field.Values) > 0 && !slices.Contains(field.Values, s) {
+				return fmt.Errorf("parameter '%s' must be one of %v, got '%s'", name, field.Values, s)
+			}
+		case "int":
+			switch n := v.(type) {
+			case int:
+				valid = true
+			case float64:
+				// numbers decoded from json
+				valid = n == float64(int(n))
+			}
+		case "bool":
+			_, valid = v.(bool)
+		}
+		if !valid {
+			return fmt.Errorf("parameter '%s' must be of type %s", name, field.Type)
+		}
+	}
+	return nil
 }
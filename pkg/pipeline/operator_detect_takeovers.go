@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// IssueSubdomainTakeover is the kind of the issues raised on domains that point
+	// to a deprovisioned resource of a third-party service
+	IssueSubdomainTakeover = "subdomain-takeover"
+	// IssueDanglingCNAME is the kind of the issues raised on domains whose
+	// CNAME chain ends in a name that does not exist
+	IssueDanglingCNAME = "dangling-cname"
+
+	// maxTakeoverBodySize is the number of bytes at the beginning of a response searched
+	// for a fingerprint. The pages of deprovisioned resources are small
+	maxTakeoverBodySize = 64 << 10
+)
+
+//go:embed takeover_fingerprints.yaml
+var takeoverFingerprintsFile []byte
+
+// TakeoverFingerprint describes how a deprovisioned resource of a
+// third-party service looks like, from the domains pointing to it
+type TakeoverFingerprint struct {
+	// Service is the name of the third-party service
+	Service string `yaml:"service"`
+	// CNAMEs are the suffixes of the names that point to the service
+	CNAMEs []string `yaml:"cnames"`
+	// Fingerprints are strings found in the response body of a deprovisioned resource
+	Fingerprints []string `yaml:"fingerprints"`
+	// NXDOMAIN is set when a CNAME to the service that does not exist
+	// is enough to claim the resource
+	NXDOMAIN bool `yaml:"nxdomain"`
+}
+
+// BodyFetchFunc returns the beginning of the response body of url
+type BodyFetchFunc func(ctx context.Context, url string) (string, error)
+
+// TakeoverFingerprints returns the fingerprint table embedded in the binary
+func TakeoverFingerprints() ([]TakeoverFingerprint, error) {
+	var fingerprints []TakeoverFingerprint
+	if err := yaml.Unmarshal(takeoverFingerprintsFile, &fingerprints); err != nil {
+		return nil, fmt.Errorf("Failed to parse the takeover fingerprints: %w", err)
+	}
+	for i, f := range fingerprints {
+		if f.Service == "" || len(f.CNAMEs) == 0 {
+			return nil, fmt.Errorf("takeover fingerprint at index %d has no service or cnames", i)
+		}
+		if len(f.Fingerprints) == 0 && !f.NXDOMAIN {
+			return nil, fmt.Errorf("takeover fingerprint %q can never match", f.Service)
+		}
+	}
+	return fingerprints, nil
+}
+
+// NewBodyFetcher returns a BodyFetchFunc that respects the timeout and the rate limit
+// of the httpx configuration.
+// Redirects are not followed: the response of the domain itself is the one
+// served by the deprovisioned resource.
+func NewBodyFetcher(config HttpxConfig) BodyFetchFunc {
+	client := &http.Client{
+		Timeout: time.Duration(config.Timeout) * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{
+			// the services serve their error pages with their own certificate,
+			// which never matches the domain pointing to them
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	limiter := rate.NewLimiter(rate.Limit(config.RateLimit), 1)
+	return func(ctx context.Context, url string) (string, error) {
+		if err := limiter.Wait(ctx); err != nil {
+			return "", err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxTakeoverBodySize))
+		if err != nil {
+			return "", err
+		}
+		return string(body), nil
+	}
+}
+
+// DetectTakeovers raises an issue for every domain that can be taken over:
+//   - domains with a CNAME to a service of the fingerprint table, when the
+//     service does not exist or serves the page of a deprovisioned resource
+//   - domains with a CNAME chain that ends in a name that does not exist
+//
+// When a CNAME points to a service with a response fingerprint, the responses
+// httpx got from the domain are searched for it. Only the domains without a response,
+// because httpx did not probe them or they did not answer it, are fetched over https
+// and http, from threads concurrent workers.
+// It stops early, returning the context error, when the context is done.
+func DetectTakeovers(ctx context.Context, records map[string]DNSRecords, responses map[string]HTTPInfo, fingerprints []TakeoverFingerprint, fetch BodyFetchFunc, threads int) ([]issues.Issue, error) {
+	detected := []issues.Issue{}
+	takeover := func(domain string, f TakeoverFingerprint, target string) issues.Issue {
+		return issues.New(IssueSubdomainTakeover, domain, serviceSlug(f.Service),
+			fmt.Sprintf("CNAME to %s serves the page of a deprovisioned %s resource, that can be claimed", target, f.Service))
+	}
+
+	bodies := responseBodies(responses)
+	type unprobed struct {
+		domain      string
+		fingerprint TakeoverFingerprint
+		target      string
+	}
+	var toFetch []unprobed
+	for domain, r := range records {
+		if len(r.CNAME) == 0 {
+			continue
+		}
+
+		f, target, found := matchTakeoverFingerprint(r.CNAME, fingerprints)
+		switch {
+		case found && r.NXDOMAIN && f.NXDOMAIN:
+			detected = append(detected, issues.New(IssueSubdomainTakeover, domain, serviceSlug(f.Service),
+				fmt.Sprintf("CNAME to %s does not exist, and can be claimed on %s", target, f.Service)))
+		case found && !r.NXDOMAIN && len(f.Fingerprints) > 0:
+			domainBodies, probed := bodies[normalize_domain(domain)]
+			if !probed {
+				toFetch = append(toFetch, unprobed{domain, f, target})
+			} else if takeoverFingerprintFound(domainBodies, f) {
+				detected = append(detected, takeover(domain, f, target))
+			}
+		case r.NXDOMAIN:
+			detected = append(detected, issues.New(IssueDanglingCNAME, domain, "",
+				fmt.Sprintf("CNAME to %s does not resolve: if the name can be registered, the domain can be taken over", r.CanonicalName())))
+		}
+	}
+
+	jobs := make(chan unprobed)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(max(threads, 1), len(toFetch)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if takeoverFingerprintMatches(ctx, job.domain, job.fingerprint, fetch) {
+					mu.Lock()
+					detected = append(detected, takeover(job.domain, job.fingerprint, job.target))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+feed:
+	for _, job := range toFetch {
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	issues.Sort(detected)
+	return detected, nil
+}
+
+// responseBodies groups the bodies of the http responses by domain
+func responseBodies(responses map[string]HTTPInfo) map[string][]string {
+	bodies := map[string][]string{}
+	for rawURL, info := range responses {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domain := normalize_domain(u.Hostname())
+		bodies[domain] = append(bodies[domain], info.Body)
+	}
+	return bodies
+}
+
+// matchTakeoverFingerprint returns the first fingerprint with a suffix
+// matching a name of the chain, and the matching name
+func matchTakeoverFingerprint(chain []string, fingerprints []TakeoverFingerprint) (TakeoverFingerprint, string, bool) {
+	for _, name := range chain {
+		for _, f := range fingerprints {
+			for _, suffix := range f.CNAMEs {
+				suffix = normalize_domain(strings.TrimPrefix(suffix, "."))
+				if name == suffix || strings.HasSuffix(name, "."+suffix) {
+					return f, name, true
+				}
+			}
+		}
+	}
+	return TakeoverFingerprint{}, "", false
+}
+
+// takeoverFingerprintMatches fetches the response of domain, over https
+// and http, and reports whether it contains one of the fingerprints of the service.
+// Domains that don't respond are not reported: there is no evidence
+// that the resource was deprovisioned.
+func takeoverFingerprintMatches(ctx context.Context, domain string, f TakeoverFingerprint, fetch BodyFetchFunc) bool {
+	for _, scheme := range []string{"https", "http"} {
+		body, err := fetch(ctx, scheme+"://"+domain)
+		if ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			continue
+		}
+		if takeoverFingerprintFound([]string{body}, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// takeoverFingerprintFound reports whether one of the bodies contains
+// one of the fingerprints of the service
+func takeoverFingerprintFound(bodies []string, f TakeoverFingerprint) bool {
+	for _, body := range bodies {
+		body = body[:min(len(body), maxTakeoverBodySize)]
+		for _, fingerprint := range f.Fingerprints {
+			if strings.Contains(body, fingerprint) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serviceSlug turns the name of a service into a part of an issue ID
+func serviceSlug(service string) string {
+	return strings.Join(strings.Fields(strings.ToLower(service)), "-")
+}
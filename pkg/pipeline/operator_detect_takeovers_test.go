@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTakeoverFingerprints(t *testing.T) {
+	fingerprints, err := TakeoverFingerprints()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(fingerprints) == 0 {
+		t.Fatalf("Expected the embedded fingerprint table to be populated")
+	}
+
+	services := map[string]bool{}
+	for _, f := range fingerprints {
+		if services[f.Service] {
+			t.Errorf("Service %q is listed more than once", f.Service)
+		}
+		services[f.Service] = true
+	}
+	for _, service := range []string{"AWS S3", "Microsoft Azure", "GitHub Pages", "Heroku"} {
+		if !services[service] {
+			t.Errorf("Expected %q to be in the fingerprint table", service)
+		}
+	}
+}
+
+func TestDetectTakeovers(t *testing.T) {
+	fingerprints := []TakeoverFingerprint{
+		{Service: "GitHub Pages", CNAMEs: []string{".github.io"}, Fingerprints: []string{"There isn't a GitHub Pages site here."}},
+		{Service: "Microsoft Azure", CNAMEs: []string{".azurewebsites.net"}, NXDOMAIN: true},
+		{Service: "Heroku", CNAMEs: []string{".herokuapp.com"}, Fingerprints: []string{"No such app"}},
+	}
+	bodies := map[string]string{
+		"https://docs.example.com":  "<h1>404</h1><p>There isn't a GitHub Pages site here.</p>",
+		"https://blog.example.com":  "<h1>Welcome to the blog</h1>",
+		"http://legacy.example.com": "<title>No such app</title>",
+	}
+	fetch := func(ctx context.Context, url string) (string, error) {
+		body, found := bodies[url]
+		if !found {
+			return "", errors.New("connection refused")
+		}
+		return body, nil
+	}
+
+	records := map[string]DNSRecords{
+		// deprovisioned github pages site
+		"docs.example.com": {CNAME: []string{"example.github.io"}, A: []string{"185.199.108.153"}},
+		// live github pages site
+		"blog.example.com": {CNAME: []string{"blog-example.github.io"}, A: []string{"185.199.108.153"}},
+		// the fingerprint is only served over http, and the service is matched down the chain
+		"legacy.example.com": {CNAME: []string{"legacy.example.net", "legacy-example.herokuapp.com"}, A: []string{"192.0.2.1"}},
+		// deleted azure app service
+		"app.example.com": {CNAME: []string{"example-app.azurewebsites.net"}, NXDOMAIN: true},
+		// the CNAME of an unknown provider points nowhere
+		"old.example.com": {CNAME: []string{"old.example-cdn.net"}, NXDOMAIN: true},
+		// the service does not respond: no evidence
+		"down.example.com": {CNAME: []string{"down.herokuapp.com"}, A: []string{"192.0.2.2"}},
+		// not an alias
+		"www.example.com":  {A: []string{"192.0.2.3"}},
+		"gone.example.com": {NXDOMAIN: true},
+	}
+
+	detected, err := DetectTakeovers(context.Background(), records, nil, fingerprints, fetch, 4)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var ids []string
+	for _, issue := range detected {
+		ids = append(ids, issue.ID)
+	}
+	expected := []string{
+		"dangling-cname:old.example.com",
+		"subdomain-takeover:github-pages:docs.example.com",
+		"subdomain-takeover:heroku:legacy.example.com",
+		"subdomain-takeover:microsoft-azure:app.example.com",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Issues mismatch.\nExpected: %v\nGot: %v", expected, ids)
+	}
+}
+
+func TestDetectTakeoversHttpxResponses(t *testing.T) {
+	fingerprints := []TakeoverFingerprint{
+		{Service: "GitHub Pages", CNAMEs: []string{".github.io"}, Fingerprints: []string{"There isn't a GitHub Pages site here."}},
+	}
+	responses := map[string]HTTPInfo{
+		"https://docs.example.com":      {StatusCode: 404, Body: "<p>There isn't a GitHub Pages site here.</p>"},
+		"http://blog.example.com:8080/": {StatusCode: 200, Body: "<h1>Welcome to the blog</h1>"},
+	}
+	var mu sync.Mutex
+	var fetched []string
+	fetch := func(ctx context.Context, url string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetched = append(fetched, url)
+		return "There isn't a GitHub Pages site here.", nil
+	}
+
+	records := map[string]DNSRecords{
+		// the fingerprint is in the httpx response
+		"docs.example.com": {CNAME: []string{"example.github.io"}, A: []string{"185.199.108.153"}},
+		// httpx got a live page, on any port
+		"blog.example.com": {CNAME: []string{"blog-example.github.io"}, A: []string{"185.199.108.153"}},
+		// httpx got no response: the domain is fetched
+		"wiki.example.com": {CNAME: []string{"wiki-example.github.io"}, A: []string{"185.199.108.153"}},
+	}
+
+	detected, err := DetectTakeovers(context.Background(), records, responses, fingerprints, fetch, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var ids []string
+	for _, issue := range detected {
+		ids = append(ids, issue.ID)
+	}
+	expected := []string{
+		"subdomain-takeover:github-pages:docs.example.com",
+		"subdomain-takeover:github-pages:wiki.example.com",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Issues mismatch.\nExpected: %v\nGot: %v", expected, ids)
+	}
+	if !reflect.DeepEqual(fetched, []string{"https://wiki.example.com"}) {
+		t.Errorf("Expected only the domain without a response to be fetched, got: %v", fetched)
+	}
+}
+
+func TestDetectTakeoversCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	records := map[string]DNSRecords{"old.example.com": {CNAME: []string{"old.example.net"}, NXDOMAIN: true}}
+	_, err := DetectTakeovers(ctx, records, nil, nil, nil, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a context error, got: %v", err)
+	}
+}
+
+func TestNewBodyFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "There isn't a GitHub Pages site here.")
+	}))
+	defer server.Close()
+
+	fetch := NewBodyFetcher(HttpxConfig{Timeout: 5, RateLimit: 100})
+	body, err := fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(body, "GitHub Pages") {
+		t.Errorf("Unexpected body: %q", body)
+	}
+
+	// redirects are not followed
+	body, err = fetch(context.Background(), server.URL+"/redirect")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if strings.Contains(body, "GitHub Pages") {
+		t.Errorf("Expected the redirect not to be followed, got: %q", body)
+	}
+}
@@ -0,0 +1,34 @@
+package pipeline
+
+// ExcludeByIP takes a list of resolved domains, and removes the ones
+// whose IPs are all excluded. The exclusions are left untouched.
+// Domains that are not in the cache, or that did not resolve, are kept:
+// there is no evidence that they point to an excluded network.
+func ExcludeByIP(domains []string, cache *DNSCache, exclusions *Exclusions) (kept []string, excluded []string) {
+	kept = []string{}
+	excluded = []string{}
+
+	for _, domain := range domains {
+		ips, _ := cache.Get(domain)
+		if allIPsExcluded(ips, exclusions) {
+			excluded = append(excluded, domain)
+		} else {
+			kept = append(kept, domain)
+		}
+	}
+
+	return kept, excluded
+}
+
+// allIPsExcluded reports whether there are ips, and all of them are excluded
+func allIPsExcluded(ips []string, exclusions *Exclusions) bool {
+	if len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !exclusions.Contains_ip(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExcludeByIP(t *testing.T) {
+	tests := []struct {
+		name             string
+		domains          []string
+		resolved         map[string][]string
+		exclusions       []string
+		expectedKept     []string
+		expectedExcluded []string
+	}{
+		{
+			name:             "no exclusions",
+			domains:          []string{"a.example.com", "b.example.com"},
+			resolved:         map[string][]string{"a.example.com": {"192.0.2.1"}},
+			exclusions:       []string{},
+			expectedKept:     []string{"a.example.com", "b.example.com"},
+			expectedExcluded: []string{},
+		},
+		{
+			name:    "all records excluded",
+			domains: []string{"shop.example.com", "www.example.com"},
+			resolved: map[string][]string{
+				"shop.example.com": {"203.0.113.10", "203.0.113.11", "2001:db8::10"},
+				"www.example.com":  {"192.0.2.1"},
+			},
+			exclusions:       []string{"203.0.113.0/24", "2001:db8::/32"},
+			expectedKept:     []string{"www.example.com"},
+			expectedExcluded: []string{"shop.example.com"},
+		},
+		{
+			name:    "some records excluded",
+			domains: []string{"mixed.example.com"},
+			resolved: map[string][]string{
+				"mixed.example.com": {"203.0.113.10", "192.0.2.1"},
+			},
+			exclusions:       []string{"203.0.113.0/24"},
+			expectedKept:     []string{"mixed.example.com"},
+			expectedExcluded: []string{},
+		},
+		{
+			name:    "unresolved domains are kept",
+			domains: []string{"nxdomain.example.com", "uncached.example.com"},
+			resolved: map[string][]string{
+				"nxdomain.example.com": {},
+			},
+			exclusions:       []string{"0.0.0.0/0", "::/0"},
+			expectedKept:     []string{"nxdomain.example.com", "uncached.example.com"},
+			expectedExcluded: []string{},
+		},
+		{
+			name:    "ip ranges",
+			domains: []string{"vendor.example.com"},
+			resolved: map[string][]string{
+				"vendor.example.com": {"192.168.0.15"},
+			},
+			exclusions:       []string{"192.168.0.10-192.168.0.20"},
+			expectedKept:     []string{},
+			expectedExcluded: []string{"vendor.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewDNSCache()
+			for domain, ips := range tt.resolved {
+				cache.Set(domain, ips)
+			}
+			exclusions := MakeExclusion()
+			exclusions.Insert(&Surface{IPs: tt.exclusions})
+
+			kept, excluded := ExcludeByIP(tt.domains, cache, &exclusions)
+			if !reflect.DeepEqual(kept, tt.expectedKept) {
+				t.Errorf("kept = %v, want %v", kept, tt.expectedKept)
+			}
+			if !reflect.DeepEqual(excluded, tt.expectedExcluded) {
+				t.Errorf("excluded = %v, want %v", excluded, tt.expectedExcluded)
+			}
+
+			// the exclusions are shared by the whole run: they are left untouched
+			for _, domain := range tt.expectedExcluded {
+				if exclusions.Contains_domain(domain) {
+					t.Errorf("%s was added to the exclusions", domain)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"slices"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ExcludeWildcardAnswers takes a list of resolved domains, and removes the ones
+// that only resolve to addresses of a wildcard of the same registrable domain.
+// They are not under a detected wildcard, but they resolve because they land on one
+// that could not be detected, like *.example.com when only a.example.com is in scope.
+// Domains that are not in the cache, or that did not resolve, are kept.
+func ExcludeWildcardAnswers(domains []string, cache *DNSCache, wildcards []Wildcard) (kept []string, excluded []string) {
+	kept = []string{}
+	excluded = []string{}
+
+	// addresses of the wildcards, by registrable domain
+	wildcardIPs := map[string][]string{}
+	for _, wildcard := range wildcards {
+		base, err := publicsuffix.EffectiveTLDPlusOne(normalize_domain(wildcard.Domain))
+		if err != nil {
+			continue
+		}
+		wildcardIPs[base] = append(wildcardIPs[base], wildcard.IPs...)
+	}
+
+	for _, domain := range domains {
+		ips, found := cache.Get(domain)
+		base, err := publicsuffix.EffectiveTLDPlusOne(normalize_domain(domain))
+		if !found || len(ips) == 0 || err != nil || len(wildcardIPs[base]) == 0 {
+			kept = append(kept, domain)
+			continue
+		}
+
+		allWildcard := true
+		for _, ip := range ips {
+			if !slices.Contains(wildcardIPs[base], ip) {
+				allWildcard = false
+				break
+			}
+		}
+
+		if allWildcard {
+			excluded = append(excluded, domain)
+		} else {
+			kept = append(kept, domain)
+		}
+	}
+
+	return kept, excluded
+}
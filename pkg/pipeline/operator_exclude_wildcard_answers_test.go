@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExcludeWildcardAnswers(t *testing.T) {
+	// *.test.com exists, but only a.test.com is in scope
+	wildcards := []Wildcard{{Domain: "a.test.com", Depth: 1, IPs: []string{"192.0.2.1", "192.0.2.2"}}}
+
+	tests := []struct {
+		name             string
+		domains          []string
+		resolved         map[string][]string
+		expectedKept     []string
+		expectedExcluded []string
+	}{
+		{
+			name:    "landed on the wildcard",
+			domains: []string{"a-dev.test.com", "a-staging.test.com"},
+			resolved: map[string][]string{
+				"a-dev.test.com":     {"192.0.2.1"},
+				"a-staging.test.com": {"192.0.2.2", "192.0.2.1"},
+			},
+			expectedKept:     []string{},
+			expectedExcluded: []string{"a-dev.test.com", "a-staging.test.com"},
+		},
+		{
+			name:    "own addresses",
+			domains: []string{"api.test.com", "mixed.test.com"},
+			resolved: map[string][]string{
+				"api.test.com":   {"198.51.100.1"},
+				"mixed.test.com": {"192.0.2.1", "198.51.100.2"},
+			},
+			expectedKept:     []string{"api.test.com", "mixed.test.com"},
+			expectedExcluded: []string{},
+		},
+		{
+			name:    "same addresses on another registrable domain",
+			domains: []string{"www.example.com"},
+			resolved: map[string][]string{
+				"www.example.com": {"192.0.2.1"},
+			},
+			expectedKept:     []string{"www.example.com"},
+			expectedExcluded: []string{},
+		},
+		{
+			name:    "unresolved domains are kept",
+			domains: []string{"nxdomain.test.com", "uncached.test.com"},
+			resolved: map[string][]string{
+				"nxdomain.test.com": {},
+			},
+			expectedKept:     []string{"nxdomain.test.com", "uncached.test.com"},
+			expectedExcluded: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewDNSCache()
+			for domain, ips := range tt.resolved {
+				cache.Set(domain, ips)
+			}
+
+			kept, excluded := ExcludeWildcardAnswers(tt.domains, cache, wildcards)
+			if !reflect.DeepEqual(kept, tt.expectedKept) {
+				t.Errorf("kept = %v, want %v", kept, tt.expectedKept)
+			}
+			if !reflect.DeepEqual(excluded, tt.expectedExcluded) {
+				t.Errorf("excluded = %v, want %v", excluded, tt.expectedExcluded)
+			}
+		})
+	}
+}
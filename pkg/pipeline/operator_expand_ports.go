@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+
+	"github.com/robalb/tinyasm/pkg/iprange"
+)
+
+// maxExpandedAddresses is the size of the largest network that
+// is expanded into its addresses to be probed on custom ports
+const maxExpandedAddresses = 1 << 16
+
+// ExpandPorts takes a list of domains, IPs, CIDRs and IP ranges, and returns
+// all the host:port services obtained combining every host with every port.
+// Networks are expanded into their addresses. Networks larger than
+// maxExpandedAddresses are not expanded, and are returned in skipped.
+func ExpandPorts(hosts []string, ports []int) (services []string, skipped []string) {
+	services = []string{}
+	skipped = []string{}
+	if len(ports) == 0 {
+		return services, skipped
+	}
+
+	for _, host := range hosts {
+		prefixes, err := iprange.Parse(host)
+		if err != nil {
+			// not an ip: a domain
+			for _, port := range ports {
+				services = append(services, net.JoinHostPort(normalize_domain(host), strconv.Itoa(port)))
+			}
+			continue
+		}
+
+		if countAddresses(prefixes) > maxExpandedAddresses {
+			skipped = append(skipped, host)
+			continue
+		}
+		for _, prefix := range prefixes {
+			for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+				for _, port := range ports {
+					services = append(services, net.JoinHostPort(addr.String(), strconv.Itoa(port)))
+				}
+			}
+		}
+	}
+
+	return services, skipped
+}
+
+// countAddresses returns the number of addresses covered by the prefixes,
+// capped to maxExpandedAddresses+1
+func countAddresses(prefixes []netip.Prefix) int {
+	count := 0
+	for _, prefix := range prefixes {
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		if hostBits > 16 {
+			return maxExpandedAddresses + 1
+		}
+		count += 1 << hostBits
+		if count > maxExpandedAddresses {
+			return maxExpandedAddresses + 1
+		}
+	}
+	return count
+}
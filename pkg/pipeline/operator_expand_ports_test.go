@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandPorts(t *testing.T) {
+	tests := []struct {
+		name             string
+		hosts            []string
+		ports            []int
+		expectedServices []string
+		expectedSkipped  []string
+	}{
+		{
+			name:             "no ports",
+			hosts:            []string{"example.com", "192.0.2.1"},
+			ports:            []int{},
+			expectedServices: []string{},
+			expectedSkipped:  []string{},
+		},
+		{
+			name:  "domains and ips",
+			hosts: []string{"Example.com.", "192.0.2.1", "2001:db8::1"},
+			ports: []int{80, 8443},
+			expectedServices: []string{
+				"example.com:80", "example.com:8443",
+				"192.0.2.1:80", "192.0.2.1:8443",
+				"[2001:db8::1]:80", "[2001:db8::1]:8443",
+			},
+			expectedSkipped: []string{},
+		},
+		{
+			name:  "cidrs and ranges are expanded",
+			hosts: []string{"192.0.2.0/31", "198.51.100.10-198.51.100.12"},
+			ports: []int{8080},
+			expectedServices: []string{
+				"192.0.2.0:8080", "192.0.2.1:8080",
+				"198.51.100.10:8080", "198.51.100.11:8080", "198.51.100.12:8080",
+			},
+			expectedSkipped: []string{},
+		},
+		{
+			name:             "large networks are skipped",
+			hosts:            []string{"10.0.0.0/8", "2001:db8::/64", "192.0.2.1"},
+			ports:            []int{22},
+			expectedServices: []string{"192.0.2.1:22"},
+			expectedSkipped:  []string{"10.0.0.0/8", "2001:db8::/64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services, skipped := ExpandPorts(tt.hosts, tt.ports)
+			if !reflect.DeepEqual(services, tt.expectedServices) {
+				t.Errorf("Services mismatch.\nExpected: %v\nGot: %v", tt.expectedServices, services)
+			}
+			if !reflect.DeepEqual(skipped, tt.expectedSkipped) {
+				t.Errorf("Skipped mismatch.\nExpected: %v\nGot: %v", tt.expectedSkipped, skipped)
+			}
+		})
+	}
+}
@@ -2,8 +2,16 @@ package pipeline
 
 // insert_safe_string inserts all elements from source into target,
 // avoiding duplicates and excluded values.
+// Every inserted or already present value is recorded in the provenance
+// as discovered by origin. A nil provenance disables the tracking.
 // Note: the target will be modified in place
-func insert_safe_string(source []string, checkExclusion func(string) bool, target *[]string) {
+func insert_safe_string(
+	source []string,
+	checkExclusion func(string) bool,
+	target *[]string,
+	provenance *Provenance,
+	origin string,
+) {
 	// Create a map to track existing values in target for O(1) lookup
 	existing := make(map[string]struct{})
 	for _, val := range *target {
@@ -17,6 +25,10 @@ func insert_safe_string(source []string, checkExclusion func(string) bool, targe
 			continue
 		}
 
+		if provenance != nil {
+			provenance.Record(val, origin)
+		}
+
 		// Skip if value already exists in target
 		if _, exists := existing[val]; exists {
 			continue
@@ -31,13 +43,16 @@ func insert_safe_string(source []string, checkExclusion func(string) bool, targe
 // insert_safe inserts all elements of a Surface from source into target,
 // avoiding duplicates and excluded values.
 // Note: the target will be modified in place
-func insert_safe(source Surface, exclusions Exclusions, target *Surface) {
+func insert_safe(source Surface, exclusions Exclusions, target *Surface, provenance *Provenance, origin string) {
 	// Handle domains
-	insert_safe_string(source.Domains, exclusions.Contains_domain, &target.Domains)
+	insert_safe_string(source.Domains, exclusions.Contains_domain, &target.Domains, provenance, origin)
 
 	// Handle IPs
-	insert_safe_string(source.IPs, exclusions.Contains_ip, &target.IPs)
+	insert_safe_string(source.IPs, exclusions.Contains_ip, &target.IPs, provenance, origin)
 
 	// Handle URLs
-	insert_safe_string(source.URLs, exclusions.Contains_url, &target.URLs)
+	insert_safe_string(source.URLs, exclusions.Contains_url, &target.URLs, provenance, origin)
+
+	// Handle services
+	insert_safe_string(source.Services, exclusions.Contains_service, &target.Services, provenance, origin)
 }
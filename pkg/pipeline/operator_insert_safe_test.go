@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInsertSafeStringProvenance(t *testing.T) {
+	previousRun := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := previousRun.Add(24 * time.Hour)
+
+	provenance := NewProvenance(map[string]Asset{
+		"known.example.com": {
+			Sources:   []string{SourceSubfinder},
+			FirstSeen: previousRun,
+			LastSeen:  previousRun,
+		},
+		"stale.example.com": {
+			Sources:   []string{SourceAlterx},
+			FirstSeen: previousRun,
+			LastSeen:  previousRun,
+		},
+	}, now)
+
+	exclusions := MakeExclusion()
+	exclusions.Insert(&Surface{Domains: []string{"excluded.example.com"}})
+
+	target := []string{}
+	insert_safe_string(
+		[]string{"known.example.com", "stale.example.com"},
+		exclusions.Contains_domain, &target, nil, "",
+	)
+	insert_safe_string(
+		[]string{"example.com", "excluded.example.com"},
+		exclusions.Contains_domain, &target, provenance, SourceScope,
+	)
+	insert_safe_string(
+		[]string{"known.example.com", "new.example.com", "example.com"},
+		exclusions.Contains_domain, &target, provenance, SourceSubfinder,
+	)
+
+	expectedTarget := []string{"known.example.com", "stale.example.com", "example.com", "new.example.com"}
+	if !reflect.DeepEqual(target, expectedTarget) {
+		t.Errorf("target = %v, want %v", target, expectedTarget)
+	}
+
+	expectedSeen := []string{"known.example.com", "example.com", "new.example.com"}
+	if seen := provenance.Seen(target); !reflect.DeepEqual(seen, expectedSeen) {
+		t.Errorf("Seen() = %v, want %v", seen, expectedSeen)
+	}
+
+	expectedAssets := map[string]Asset{
+		"known.example.com": {
+			Sources:   []string{SourceSubfinder},
+			FirstSeen: previousRun,
+			LastSeen:  now,
+		},
+		"stale.example.com": {
+			Sources:   []string{SourceAlterx},
+			FirstSeen: previousRun,
+			LastSeen:  previousRun,
+		},
+		"example.com": {
+			Sources:   []string{SourceScope, SourceSubfinder},
+			FirstSeen: now,
+			LastSeen:  now,
+		},
+		"new.example.com": {
+			Sources:   []string{SourceSubfinder},
+			FirstSeen: now,
+			LastSeen:  now,
+		},
+	}
+	assets := provenance.Assets(Surface{Domains: target})
+	if !reflect.DeepEqual(assets, expectedAssets) {
+		t.Errorf("Assets() = %v, want %v", assets, expectedAssets)
+	}
+
+	if _, exists := provenance.Get("excluded.example.com"); exists {
+		t.Errorf("Excluded values should not be recorded")
+	}
+}
@@ -0,0 +1,33 @@
+package pipeline
+
+// SelectWildcardChildren takes a list of domains, and returns the ones
+// under at least one wildcard. They resolve whether they exist or not,
+// and can't be tested over dns.
+func SelectWildcardChildren(domains []string, wildcards []Wildcard) []string {
+	var result []string
+	for _, domain := range domains {
+		for _, wildcard := range wildcards {
+			if wildcard.Covers(domain) {
+				result = append(result, domain)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// SelectWildcardParents takes a list of domains, and returns the ones
+// whose children are under at least one wildcard. Every permutation
+// generated from them resolves, so they can't be fuzzed.
+func SelectWildcardParents(domains []string, wildcards []Wildcard) []string {
+	var result []string
+	for _, domain := range domains {
+		for _, wildcard := range wildcards {
+			if wildcard.CoversChildren(domain) {
+				result = append(result, domain)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,26 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectWildcardChildren(t *testing.T) {
+	wildcards := []Wildcard{
+		{Domain: "a.example.com", Depth: 1},
+		{Domain: "test.com", Depth: 2},
+	}
+	domains := []string{"a.example.com", "b.a.example.com", "b.example.com", "test.com", "b.test.com", "c.b.test.com"}
+
+	children := SelectWildcardChildren(domains, wildcards)
+	expected := []string{"b.a.example.com", "c.b.test.com"}
+	if !reflect.DeepEqual(children, expected) {
+		t.Errorf("SelectWildcardChildren() = %v, want %v", children, expected)
+	}
+
+	parents := SelectWildcardParents(domains, wildcards)
+	expected = []string{"a.example.com", "b.a.example.com", "b.test.com", "c.b.test.com"}
+	if !reflect.DeepEqual(parents, expected) {
+		t.Errorf("SelectWildcardParents() = %v, want %v", parents, expected)
+	}
+}
@@ -0,0 +1,390 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+func testEnv(scopeExclusion Surface) *Env {
+	config := DefaultConfig()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewEnv(logger, nil, &Surface{}, nil, &Surface{}, &scopeExclusion, &config)
+}
+
+func TestOperatorsRegistry(t *testing.T) {
+	ops := Operators()
+	if len(ops) == 0 {
+		t.Fatal("no operators registered")
+	}
+	for i, op := range ops {
+		if i > 0 && ops[i-1].Name() >= op.Name() {
+			t.Errorf("operators not sorted: %s before %s", ops[i-1].Name(), op.Name())
+		}
+		if op.Description() == "" {
+			t.Errorf("operator %s has no description", op.Name())
+		}
+		for _, socket := range append(op.Inputs(), op.Outputs()...) {
+			if !socket.Type.Check(zeroValue(socket.Type)) {
+				t.Errorf("operator %s: socket %s has unknown type %s", op.Name(), socket.Name, socket.Type)
+			}
+		}
+	}
+
+	if _, exists := LookupOperator("merge"); !exists {
+		t.Error("operator merge not found")
+	}
+	if _, exists := LookupOperator("nonexistent"); exists {
+		t.Error("operator nonexistent found")
+	}
+}
+
+func zeroValue(t DataType) any {
+	for _, v := range []any{Surface{}, []string{}, []int{}, []Wildcard{}, map[string]HTTPInfo{}, map[string]DNSRecords{}, []issues.Issue{}} {
+		if t.Check(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func TestValidateParams(t *testing.T) {
+	schema := []ConfigField{
+		sourceParam,
+		{Name: "threads", Type: "int"},
+		{Name: "verbose", Type: "bool"},
+	}
+
+	tests := []struct {
+		name        string
+		params      Params
+		errContains string
+	}{
+		{name: "empty", params: nil},
+		{name: "valid", params: Params{"source": SourceHttpx, "threads": 4, "verbose": true}},
+		{name: "int from json", params: Params{"threads": float64(4)}},
+		{name: "fractional int", params: Params{"threads": 4.5}, errContains: "must be of type int"},
+		{name: "unknown", params: Params{"sauce": "x"}, errContains: "unknown parameter 'sauce'"},
+		{name: "wrong type", params: Params{"verbose": "yes"}, errContains: "must be of type bool"},
+		{name: "not allowed", params: Params{"source": "shodan"}, errContains: "must be one of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateParams(schema, tt.params)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestNodeValidate(t *testing.T) {
+	config := DefaultConfig()
+	tests := []struct {
+		name        string
+		node        Node
+		errContains string
+	}{
+		{
+			name: "valid",
+			node: Node{ID: "n", Operator: "insert_domains", Params: Params{"source": SourceDNS},
+				Inputs: map[string]string{"surface": "a.surface", "items": "b.domains"}},
+		},
+		{
+			name: "optional input not connected",
+			node: Node{ID: "n", Operator: "detect_wildcards", Inputs: map[string]string{"domains": "a.domains"}},
+		},
+		{
+			name:        "unknown operator",
+			node:        Node{ID: "n", Operator: "nmap"},
+			errContains: "unknown operator 'nmap'",
+		},
+		{
+			name:        "unknown input",
+			node:        Node{ID: "n", Operator: "resolve", Inputs: map[string]string{"domains": "a.domains", "ips": "a.ips"}},
+			errContains: "has no input 'ips'",
+		},
+		{
+			name:        "required input not connected",
+			node:        Node{ID: "n", Operator: "merge", Inputs: map[string]string{"a": "a.surface"}},
+			errContains: "input 'b' is not connected",
+		},
+		{
+			name:        "invalid params",
+			node:        Node{ID: "n", Operator: "merge", Params: Params{"source": 1}, Inputs: map[string]string{"a": "a.surface", "b": "b.surface"}},
+			errContains: "parameter 'source' must be of type string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.node.Validate(&config)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestMemoryExecute(t *testing.T) {
+	env := testEnv(Surface{Domains: []string{"excluded.example.com"}})
+	mem := NewMemory()
+	surface := Surface{Domains: []string{"example.com"}, URLs: []string{"https://example.com/"}}
+	if err := mem.Set("start.surface", TypeSurface, surface); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Set("found.domains", TypeDomains, []string{"www.example.com", "excluded.example.com", "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	insert := Node{ID: "insert", Operator: "insert_domains", Params: Params{"source": SourceSubfinder},
+		Inputs: map[string]string{"surface": "start.surface", "items": "found.domains"}}
+	if _, _, err := mem.Execute(context.Background(), env, &insert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	split := Node{ID: "split", Operator: "split_surface", Inputs: map[string]string{"surface": "insert.surface"}}
+	_, out, err := mem.Execute(context.Background(), env, &split)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"example.com", "www.example.com"}
+	if !reflect.DeepEqual(out["domains"], expected) {
+		t.Errorf("expected domains %v, got %v", expected, out["domains"])
+	}
+	if got, _, _ := mem.Get("split.domains"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected slot split.domains %v, got %v", expected, got)
+	}
+	if seen := env.Provenance.Seen(expected); !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected the inserted domains to be seen, got %v", seen)
+	}
+
+	// the values in the memory are never modified
+	if got, _, _ := mem.Get("start.surface"); !reflect.DeepEqual(got, surface) || len(surface.Domains) != 1 {
+		t.Errorf("input surface was modified: %v", got)
+	}
+
+	wrongType := Node{ID: "wrong", Operator: "resolve", Inputs: map[string]string{"domains": "insert.surface"}}
+	if _, _, err := mem.Execute(context.Background(), env, &wrongType); err == nil || !strings.Contains(err.Error(), "is of type domains") {
+		t.Errorf("expected a type error, got %v", err)
+	}
+
+	empty := Node{ID: "empty", Operator: "trim_subdomains", Inputs: map[string]string{"domains": "nothing.domains"}}
+	if _, _, err := mem.Execute(context.Background(), env, &empty); err == nil || !strings.Contains(err.Error(), "slot nothing.domains is empty") {
+		t.Errorf("expected an empty slot error, got %v", err)
+	}
+
+	if err := mem.Set("bad.ports", TypePorts, []string{"80"}); err == nil {
+		t.Error("expected an error writing a value of the wrong type")
+	}
+}
+
+func TestScopeOperatorAssetPorts(t *testing.T) {
+	op, _ := LookupOperator("scope")
+	env := testEnv(Surface{IPs: []string{"192.0.2.1"}, Services: []string{"admin.example.com:9000"}})
+	env.Scope = Surface{
+		Domains:  []string{"example.com"},
+		IPs:      []string{"192.0.2.0/31"},
+		Services: []string{"example.com:8080"},
+		AssetPorts: map[string][]int{
+			"admin.example.com": {8443, 9000},
+			"192.0.2.0/31":      {22},
+		},
+	}
+
+	out, err := op.Execute(context.Background(), env, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the excluded address and service are not probed
+	expected := []string{"example.com:8080", "192.0.2.0:22", "admin.example.com:8443"}
+	if got := out["surface"].(Surface).Services; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected services %v, got %v", expected, got)
+	}
+	if len(env.Scope.Services) != 1 {
+		t.Errorf("the scope of the run was modified: %v", env.Scope.Services)
+	}
+}
+
+func TestSelectFuzzableOperator(t *testing.T) {
+	op, _ := LookupOperator("select_fuzzable")
+	in := Values{
+		"domains":   []string{"example.com", "a.example.com", "b.a.example.com", "other.com"},
+		"wildcards": []Wildcard{{Domain: "a.example.com", Depth: 1}},
+	}
+	out, err := op.Execute(context.Background(), testEnv(Surface{}), nil, in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"example.com", "other.com"}
+	if !reflect.DeepEqual(out["domains"], expected) {
+		t.Errorf("expected %v, got %v", expected, out["domains"])
+	}
+}
+
+func TestExcludeDomainsByIPOperator(t *testing.T) {
+	op, _ := LookupOperator("exclude_domains_by_ip")
+	env := testEnv(Surface{IPs: []string{"203.0.113.0/24"}})
+	env.DNSCache = NewDNSCache()
+	// a child of a wildcard, with an address of its own in an excluded network
+	env.DNSCache.Set("own.wild.example.com", []string{"203.0.113.10"})
+	env.DNSCache.Set("www.wild.example.com", []string{"192.0.2.1"})
+
+	in := Values{"domains": []string{"own.wild.example.com", "www.wild.example.com"}}
+	out, err := op.Execute(context.Background(), env, nil, in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out["domains"], []string{"www.wild.example.com"}) {
+		t.Errorf("unexpected domains: %v", out["domains"])
+	}
+	if !reflect.DeepEqual(out["excluded"], []string{"own.wild.example.com"}) {
+		t.Errorf("unexpected excluded domains: %v", out["excluded"])
+	}
+	if env.Exclusions.Contains_domain("own.wild.example.com") {
+		t.Errorf("the excluded domain was added to the exclusions of the run")
+	}
+}
+
+func TestExcludeByIPOperator(t *testing.T) {
+	op, _ := LookupOperator("exclude_by_ip")
+	env := testEnv(Surface{IPs: []string{"203.0.113.0/24"}})
+	env.DNSCache = NewDNSCache()
+	env.DNSCache.Set("vendor.example.com", []string{"203.0.113.10"})
+	env.DNSCache.Set("www.example.com", []string{"192.0.2.1"})
+
+	in := Values{"surface": Surface{
+		Domains: []string{"vendor.example.com", "www.example.com"},
+		URLs:    []string{"https://vendor.example.com/login", "https://www.example.com"},
+	}}
+	out, err := op.Execute(context.Background(), env, nil, in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := out["surface"].(Surface)
+	if !reflect.DeepEqual(s.Domains, []string{"www.example.com"}) {
+		t.Errorf("unexpected domains: %v", s.Domains)
+	}
+	// the urls of the excluded domains go with them
+	if !reflect.DeepEqual(s.URLs, []string{"https://www.example.com"}) {
+		t.Errorf("unexpected urls: %v", s.URLs)
+	}
+	if !reflect.DeepEqual(out["excluded"], []string{"vendor.example.com"}) {
+		t.Errorf("unexpected excluded domains: %v", out["excluded"])
+	}
+}
+
+func TestHttpxWildcardOperatorExclusions(t *testing.T) {
+	// none of these candidates can be probed: the test never reaches httpx
+	tests := []struct {
+		name             string
+		candidates       []string
+		wildcard         Wildcard
+		expectedExcluded []string
+	}{
+		{
+			name:             "child with excluded addresses of its own",
+			candidates:       []string{"own.wild.example.com"},
+			wildcard:         Wildcard{Domain: "wild.example.com", Depth: 1, IPs: []string{"192.0.2.1"}},
+			expectedExcluded: []string{"own.wild.example.com"},
+		},
+		{
+			name:             "wildcard on excluded addresses",
+			candidates:       []string{"www.wild.example.com"},
+			wildcard:         Wildcard{Domain: "wild.example.com", Depth: 1, IPs: []string{"203.0.113.1", "203.0.113.2"}},
+			expectedExcluded: []string{},
+		},
+	}
+
+	op, _ := LookupOperator("httpx_wildcard")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := testEnv(Surface{IPs: []string{"203.0.113.0/24"}})
+			env.DNSCache = NewDNSCache()
+			env.DNSCache.Set("own.wild.example.com", []string{"203.0.113.10"})
+			env.DNSCache.Set("www.wild.example.com", tt.wildcard.IPs)
+
+			in := Values{"candidates": tt.candidates, "wildcards": []Wildcard{tt.wildcard}}
+			out, err := op.Execute(context.Background(), env, nil, in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(out["excluded"], tt.expectedExcluded) {
+				t.Errorf("expected excluded %v, got %v", tt.expectedExcluded, out["excluded"])
+			}
+			if !reflect.DeepEqual(out["domains"], []string{}) {
+				t.Errorf("expected no deviating domains, got %v", out["domains"])
+			}
+		})
+	}
+}
+
+func TestHttpxTargets(t *testing.T) {
+	surface := Surface{
+		Domains:  []string{"example.com"},
+		IPs:      []string{"192.0.2.1", "10.0.0.0/8"},
+		URLs:     []string{"https://example.com/app"},
+		Services: []string{"example.com:8443"},
+	}
+
+	tests := []struct {
+		name             string
+		ports            []int
+		exclusion        Surface
+		expectedServices []string
+		expectedSkipped  []string
+	}{
+		{
+			name:             "no ports",
+			expectedServices: []string{"example.com:8443"},
+		},
+		{
+			name:             "ports are probed besides the default ones",
+			ports:            []int{8080, 8443},
+			expectedServices: []string{"example.com:8443", "example.com:8080", "192.0.2.1:8080", "192.0.2.1:8443"},
+			expectedSkipped:  []string{"10.0.0.0/8"},
+		},
+		{
+			name:             "excluded services are not probed",
+			ports:            []int{8080},
+			exclusion:        Surface{Services: []string{"192.0.2.1:8080"}},
+			expectedServices: []string{"example.com:8443", "example.com:8080"},
+			expectedSkipped:  []string{"10.0.0.0/8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, skipped := httpxTargets(surface, tt.ports, testEnv(tt.exclusion).Exclusions)
+			// domains and ips are always probed on the default ports
+			if !reflect.DeepEqual(targets.Domains, surface.Domains) || !reflect.DeepEqual(targets.IPs, surface.IPs) || !reflect.DeepEqual(targets.URLs, surface.URLs) {
+				t.Errorf("Expected the surface to be probed as it is, got: %+v", targets)
+			}
+			if !reflect.DeepEqual(targets.Services, tt.expectedServices) {
+				t.Errorf("Expected services %v, got %v", tt.expectedServices, targets.Services)
+			}
+			if !reflect.DeepEqual(skipped, tt.expectedSkipped) {
+				t.Errorf("Expected skipped %v, got %v", tt.expectedSkipped, skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,665 @@
+package pipeline
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+// The built-in operators, wrapping the pipeline functions
+func init() {
+	for _, op := range builtinOperators() {
+		Register(op)
+	}
+}
+
+// sourceParam is the parameter of the operators that insert elements into a surface
+var sourceParam = ConfigField{
+	Name:        "source",
+	Type:        "string",
+	Description: "the source recorded as the discoverer of the inserted elements. When not set, they are not marked as seen",
+	Values:      []string{SourceScope, SourceURL, SourceSubfinder, SourceAlterx, SourceDNS, SourceHttpx},
+}
+
+// sourceProvenance returns the provenance the inserted elements are recorded in,
+// and their source. The provenance is nil when no source is set.
+func sourceProvenance(env *Env, params Params) (*Provenance, string) {
+	source := params.String(sourceParam.Name, "")
+	if source == "" {
+		return nil, ""
+	}
+	return env.Provenance, source
+}
+
+func cloneSurface(s Surface) Surface {
+	return Surface{
+		Domains:    slices.Clone(s.Domains),
+		IPs:        slices.Clone(s.IPs),
+		URLs:       slices.Clone(s.URLs),
+		Services:   slices.Clone(s.Services),
+		Ports:      slices.Clone(s.Ports),
+		Wildcards:  slices.Clone(s.Wildcards),
+		AssetPorts: maps.Clone(s.AssetPorts),
+	}
+}
+
+func validateSubfinder(_ Params, config *Config) error { return config.Subfinder.Validate() }
+func validateAlterx(_ Params, config *Config) error    { return config.Alterx.Validate() }
+func validateHttpx(_ Params, config *Config) error     { return config.Httpx.Validate() }
+func validateDNS(_ Params, config *Config) error       { return config.DNS.Validate() }
+
+// validateHttpxWildcard checks the dns config too: the candidates are resolved before probing
+func validateHttpxWildcard(params Params, config *Config) error {
+	if err := validateHttpx(params, config); err != nil {
+		return err
+	}
+	return validateDNS(params, config)
+}
+
+func builtinOperators() []*operator {
+	return []*operator{
+		// sources and sinks
+		{
+			name:        "known_surface",
+			description: "The surface known from the previous runs, with the excluded networks trimmed out",
+			outputs:     []Socket{{Name: "surface", Type: TypeSurface, Description: "the known surface"}},
+			execute: func(_ context.Context, env *Env, _ Params, _ Values) (Values, error) {
+				known := cloneSurface(env.Known)
+				known.IPs = env.Exclusions.Trim_ips(known.IPs)
+				return Values{"surface": known}, nil
+			},
+		},
+		{
+			name:        "scope",
+			description: "The scope, with the excluded networks trimmed out, the ports to probe and the declared wildcards. The ports of single assets are expanded into services",
+			outputs: []Socket{
+				{Name: "surface", Type: TypeSurface, Description: "the scope"},
+				{Name: "ports", Type: TypePorts, Description: "the ports to probe on every domain and ip"},
+				{Name: "wildcards", Type: TypeWildcards, Description: "the wildcards declared in the scope"},
+			},
+			execute: func(_ context.Context, env *Env, _ Params, _ Values) (Values, error) {
+				// networks that are partially excluded are split into the parts that are not,
+				// before they can reach any scanner
+				scope := cloneSurface(env.Scope)
+				scope.IPs = env.Exclusions.Trim_ips(scope.IPs)
+
+				// the ports of a single asset are probed on that asset only, as services
+				for _, asset := range slices.Sorted(maps.Keys(env.Scope.AssetPorts)) {
+					services, skipped := ExpandPorts([]string{asset}, env.Scope.AssetPorts[asset])
+					if len(skipped) > 0 {
+						env.Logger.Warn("pipeline - network too large to probe on its ports", "network", asset)
+					}
+					insert_safe_string(services, env.Exclusions.Contains_service, &scope.Services, nil, "")
+				}
+
+				declared := []Wildcard{}
+				for _, declaration := range env.Scope.Wildcards {
+					wildcard, err := ParseWildcard(declaration)
+					if err != nil {
+						return nil, err
+					}
+					declared = append(declared, wildcard)
+				}
+				return Values{"surface": scope, "ports": slices.Clone(env.Scope.Ports), "wildcards": declared}, nil
+			},
+		},
+		{
+			name:        "discovery",
+			description: "Collects the outcome of the run: the merged surface, and what was learned about it",
+			inputs: []Socket{
+				{Name: "surface", Type: TypeSurface, Description: "the merged surface"},
+				{Name: "wildcards", Type: TypeWildcards, Description: "the declared and detected wildcards", Optional: true},
+				{Name: "http", Type: TypeHTTP, Description: "the responses of the urls", Optional: true},
+				{Name: "dns", Type: TypeDNS, Description: "the dns records of the domains", Optional: true},
+				{Name: "issues", Type: TypeIssues, Description: "the detected issues", Optional: true},
+				{Name: "excluded_by_ip", Type: TypeDomains, Description: "the domains excluded by ip", Optional: true},
+			},
+			execute: func(_ context.Context, env *Env, _ Params, in Values) (Values, error) {
+				surface := value[Surface](in, "surface")
+				p := env.Provenance
+				env.Discovery = Discovery{
+					Surface: surface,
+					Current: Surface{
+						Domains:  p.Seen(surface.Domains),
+						IPs:      p.Seen(surface.IPs),
+						URLs:     p.Seen(surface.URLs),
+						Services: p.Seen(surface.Services),
+					},
+					Assets:       p.Assets(surface),
+					HTTP:         orEmptyMap(value[map[string]HTTPInfo](in, "http")),
+					DNS:          orEmptyMap(value[map[string]DNSRecords](in, "dns")),
+					Wildcards:    value[[]Wildcard](in, "wildcards"),
+					Issues:       append([]issues.Issue{}, value[[]issues.Issue](in, "issues")...),
+					ExcludedByIP: append([]string{}, value[[]string](in, "excluded_by_ip")...),
+				}
+				return Values{}, nil
+			},
+		},
+
+		// surface manipulation
+		{
+			name:        "merge",
+			description: "Merges two surfaces, dropping the excluded elements",
+			inputs: []Socket{
+				{Name: "a", Type: TypeSurface, Description: "the first surface"},
+				{Name: "b", Type: TypeSurface, Description: "the surface merged into the first one, recorded with the source"},
+			},
+			outputs: []Socket{{Name: "surface", Type: TypeSurface, Description: "the merged surface"}},
+			config:  []ConfigField{sourceParam},
+			execute: func(_ context.Context, env *Env, params Params, in Values) (Values, error) {
+				merged := Surface{}
+				insert_safe(value[Surface](in, "a"), *env.Exclusions, &merged, nil, "")
+				provenance, source := sourceProvenance(env, params)
+				insert_safe(value[Surface](in, "b"), *env.Exclusions, &merged, provenance, source)
+				return Values{"surface": merged}, nil
+			},
+		},
+		insertOperator(TypeDomains, "domains", (*Exclusions).Contains_domain, func(s *Surface) *[]string { return &s.Domains }),
+		insertOperator(TypeIPs, "ips", (*Exclusions).Contains_ip, func(s *Surface) *[]string { return &s.IPs }),
+		insertOperator(TypeURLs, "urls", (*Exclusions).Contains_url, func(s *Surface) *[]string { return &s.URLs }),
+		insertOperator(TypeServices, "services", (*Exclusions).Contains_service, func(s *Surface) *[]string { return &s.Services }),
+		{
+			name:        "split_surface",
+			description: "Splits a surface into its lists",
+			inputs:      []Socket{{Name: "surface", Type: TypeSurface, Description: "the surface to split"}},
+			outputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains of the surface"},
+				{Name: "ips", Type: TypeIPs, Description: "the ips of the surface"},
+				{Name: "urls", Type: TypeURLs, Description: "the urls of the surface"},
+				{Name: "services", Type: TypeServices, Description: "the services of the surface"},
+			},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				s := cloneSurface(value[Surface](in, "surface"))
+				return Values{"domains": s.Domains, "ips": s.IPs, "urls": s.URLs, "services": s.Services}, nil
+			},
+		},
+		{
+			name:        "expand_urls",
+			description: "Adds the domains and ips of the urls to a surface. The ones of the urls seen in this run are recorded with the url source",
+			inputs:      []Socket{{Name: "surface", Type: TypeSurface, Description: "the surface to expand"}},
+			outputs:     []Socket{{Name: "surface", Type: TypeSurface, Description: "the expanded surface"}},
+			execute: func(_ context.Context, env *Env, _ Params, in Values) (Values, error) {
+				s := cloneSurface(value[Surface](in, "surface"))
+				e := env.Exclusions
+
+				// only what is extracted from urls seen in this run is marked as seen
+				seenURLs := env.Provenance.Seen(s.URLs)
+
+				insert_safe_string(URLExtractDomains(s.URLs), e.Contains_domain, &s.Domains, nil, "")
+				insert_safe_string(URLExtractDomains(seenURLs), e.Contains_domain, &s.Domains, env.Provenance, SourceURL)
+
+				insert_safe_string(URLExtractIPs(s.URLs), e.Contains_ip, &s.IPs, nil, "")
+				insert_safe_string(URLExtractIPs(seenURLs), e.Contains_ip, &s.IPs, env.Provenance, SourceURL)
+				return Values{"surface": s}, nil
+			},
+		},
+		{
+			name:        "exclude_by_ip",
+			description: "Resolves the domains of a surface, and removes the ones whose ips are all excluded, with their urls",
+			inputs:      []Socket{{Name: "surface", Type: TypeSurface, Description: "the surface to filter"}},
+			outputs: []Socket{
+				{Name: "surface", Type: TypeSurface, Description: "the filtered surface"},
+				{Name: "excluded", Type: TypeDomains, Description: "the removed domains"},
+			},
+			validate: validateDNS,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				s := cloneSurface(value[Surface](in, "surface"))
+				if _, err := FilterActive(ctx, s.Domains, env.DNSCache, env.Resolver); err != nil {
+					return nil, err
+				}
+				var excluded []string
+				s.Domains, excluded = ExcludeByIP(s.Domains, env.DNSCache, env.Exclusions)
+				env.Logger.Info("pipeline - excluded by ip", "domains", excluded)
+
+				// urls of the excluded domains must go too
+				s.URLs = slices.DeleteFunc(s.URLs, func(u string) bool {
+					domains := URLExtractDomains([]string{u})
+					return len(domains) > 0 && slices.Contains(excluded, domains[0])
+				})
+				return Values{"surface": s, "excluded": excluded}, nil
+			},
+		},
+		{
+			name:        "exclude_domains_by_ip",
+			description: "Resolves a list of domains, and removes the ones whose ips are all excluded",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to filter"}},
+			outputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the remaining domains"},
+				{Name: "excluded", Type: TypeDomains, Description: "the removed domains"},
+			},
+			validate: validateDNS,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				domains := value[[]string](in, "domains")
+				if _, err := FilterActive(ctx, domains, env.DNSCache, env.Resolver); err != nil {
+					return nil, err
+				}
+				kept, excluded := ExcludeByIP(domains, env.DNSCache, env.Exclusions)
+				env.Logger.Info("pipeline - excluded by ip", "domains", excluded)
+				return Values{"domains": kept, "excluded": excluded}, nil
+			},
+		},
+
+		// lists manipulation
+		{
+			name:        "join_domains",
+			description: "Joins two lists of domains, without duplicates",
+			inputs: []Socket{
+				{Name: "a", Type: TypeDomains, Description: "the first list"},
+				{Name: "b", Type: TypeDomains, Description: "the second list"},
+			},
+			outputs: []Socket{{Name: "domains", Type: TypeDomains, Description: "the joined list"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				joined := []string{}
+				noExclusion := func(string) bool { return false }
+				insert_safe_string(value[[]string](in, "a"), noExclusion, &joined, nil, "")
+				insert_safe_string(value[[]string](in, "b"), noExclusion, &joined, nil, "")
+				return Values{"domains": joined}, nil
+			},
+		},
+		{
+			name:        "subtract_domains",
+			description: "Removes a list of domains from another",
+			inputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains to filter"},
+				{Name: "remove", Type: TypeDomains, Description: "the domains to remove"},
+			},
+			outputs: []Socket{{Name: "domains", Type: TypeDomains, Description: "the remaining domains"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				return Values{"domains": Subtract(value[[]string](in, "domains"), value[[]string](in, "remove"))}, nil
+			},
+		},
+		{
+			name:        "select_subdomains",
+			description: "Selects the domains that are, or are subdomains of, one of the selectors",
+			inputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains to filter"},
+				{Name: "selectors", Type: TypeDomains, Description: "the parent domains"},
+			},
+			outputs: []Socket{{Name: "domains", Type: TypeDomains, Description: "the selected domains"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				return Values{"domains": orEmpty(SelectSubdomains(value[[]string](in, "domains"), value[[]string](in, "selectors")))}, nil
+			},
+		},
+		{
+			name:        "trim_subdomains",
+			description: "Removes the domains that are subdomains of other domains in the list",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to trim"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the topmost domains"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				trimmed, err := TrimSubdomains(value[[]string](in, "domains"))
+				if err != nil {
+					return nil, err
+				}
+				return Values{"domains": orEmpty(trimmed)}, nil
+			},
+		},
+		{
+			name:        "url_extract_domains",
+			description: "Extracts the domains of a list of urls",
+			inputs:      []Socket{{Name: "urls", Type: TypeURLs, Description: "the urls"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains of the urls"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				return Values{"domains": orEmpty(URLExtractDomains(value[[]string](in, "urls")))}, nil
+			},
+		},
+		{
+			name:        "url_extract_ips",
+			description: "Extracts the ips of a list of urls",
+			inputs:      []Socket{{Name: "urls", Type: TypeURLs, Description: "the urls"}},
+			outputs:     []Socket{{Name: "ips", Type: TypeIPs, Description: "the ips of the urls"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				return Values{"ips": orEmpty(URLExtractIPs(value[[]string](in, "urls")))}, nil
+			},
+		},
+		{
+			name:        "select_seen",
+			description: "Selects the domains seen in this run",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to filter"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains seen in this run"}},
+			execute: func(_ context.Context, env *Env, _ Params, in Values) (Values, error) {
+				return Values{"domains": env.Provenance.Seen(value[[]string](in, "domains"))}, nil
+			},
+		},
+		{
+			name:        "select_unseen",
+			description: "Selects the domains not seen in this run, like the known domains that were not discovered again",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to filter"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains not seen in this run"}},
+			execute: func(_ context.Context, env *Env, _ Params, in Values) (Values, error) {
+				domains := value[[]string](in, "domains")
+				return Values{"domains": Subtract(domains, env.Provenance.Seen(domains))}, nil
+			},
+		},
+
+		// discovery
+		{
+			name:        "subfinder",
+			description: "Enumerates the subdomains of the domains from passive sources",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to enumerate"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the subdomains found"}},
+			validate:    validateSubfinder,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				domains, err := Subfinder(ctx, value[[]string](in, "domains"), env.Config.Subfinder)
+				if err != nil {
+					return nil, err
+				}
+				return Values{"domains": orEmpty(domains)}, nil
+			},
+		},
+		{
+			name:        "alterx",
+			description: "Generates permutations of the domains",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to permute"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the permutations, that may not exist"}},
+			validate:    validateAlterx,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				domains, err := Alterx(ctx, value[[]string](in, "domains"), env.Config.Alterx)
+				if err != nil {
+					return nil, err
+				}
+				return Values{"domains": orEmpty(domains)}, nil
+			},
+		},
+
+		// dns
+		{
+			name:        "resolve",
+			description: "Selects the domains that resolve to an ip",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to resolve"}},
+			outputs:     []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains that resolve"}},
+			validate:    validateDNS,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				active, err := FilterActive(ctx, value[[]string](in, "domains"), env.DNSCache, env.Resolver)
+				if err != nil {
+					return nil, err
+				}
+				return Values{"domains": orEmpty(active)}, nil
+			},
+		},
+		{
+			name:        "detect_wildcards",
+			description: "Detects the wildcards among the domains. The declared wildcards are not probed, and override the detection",
+			inputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains to probe"},
+				{Name: "declared", Type: TypeWildcards, Description: "the wildcards known in advance", Optional: true},
+			},
+			outputs:  []Socket{{Name: "wildcards", Type: TypeWildcards, Description: "the declared and detected wildcards"}},
+			validate: validateDNS,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				domains := value[[]string](in, "domains")
+				declared := value[[]Wildcard](in, "declared")
+
+				// declared wildcards are known: their domains and children are not probed
+				undeclared := Subtract(domains, SelectSubdomains(domains, WildcardDomains(declared)))
+				detected, err := FilterWildcards(ctx, undeclared, env.Resolver)
+				if err != nil {
+					return nil, err
+				}
+				wildcards := MergeWildcards(declared, detected)
+				env.Logger.Info("pipeline - wildcards", "wildcards", wildcards)
+				return Values{"wildcards": wildcards}, nil
+			},
+		},
+		{
+			name:        "select_fuzzable",
+			description: "Selects the domains that can be fuzzed: the ones whose children are not under a wildcard",
+			inputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains to filter"},
+				{Name: "wildcards", Type: TypeWildcards, Description: "the wildcards"},
+			},
+			outputs: []Socket{{Name: "domains", Type: TypeDomains, Description: "the fuzzable domains"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				domains := value[[]string](in, "domains")
+				unfuzzable := SelectWildcardParents(domains, value[[]Wildcard](in, "wildcards"))
+				return Values{"domains": Subtract(domains, unfuzzable)}, nil
+			},
+		},
+		{
+			name:        "split_wildcard_children",
+			description: "Splits the domains under a wildcard, that can only be tested over http, from the ones that can be tested over dns",
+			inputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains to split"},
+				{Name: "wildcards", Type: TypeWildcards, Description: "the wildcards"},
+			},
+			outputs: []Socket{
+				{Name: "covered", Type: TypeDomains, Description: "the domains under a wildcard"},
+				{Name: "uncovered", Type: TypeDomains, Description: "the other domains"},
+			},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				domains := value[[]string](in, "domains")
+				covered := orEmpty(SelectWildcardChildren(domains, value[[]Wildcard](in, "wildcards")))
+				return Values{"covered": covered, "uncovered": Subtract(domains, covered)}, nil
+			},
+		},
+		{
+			name:        "exclude_wildcard_answers",
+			description: "Removes the resolved domains that only land on the addresses of a wildcard",
+			inputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the resolved domains"},
+				{Name: "wildcards", Type: TypeWildcards, Description: "the wildcards"},
+			},
+			outputs: []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains with addresses of their own"}},
+			execute: func(_ context.Context, env *Env, _ Params, in Values) (Values, error) {
+				kept, excluded := ExcludeWildcardAnswers(value[[]string](in, "domains"), env.DNSCache, value[[]Wildcard](in, "wildcards"))
+				env.Logger.Info("pipeline - on wildcard addresses", "domains", excluded)
+				return Values{"domains": kept}, nil
+			},
+		},
+		{
+			name:        "dns_records",
+			description: "Collects the full dns records of the domains: CNAME chains for takeover checks, NS for delegation audits, TXT for SPF and DMARC reviews",
+			inputs:      []Socket{{Name: "domains", Type: TypeDomains, Description: "the domains to resolve"}},
+			outputs:     []Socket{{Name: "dns", Type: TypeDNS, Description: "the records of the domains that could be resolved"}},
+			validate:    validateDNS,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				records, err := env.Resolver.ResolveRecords(ctx, value[[]string](in, "domains"))
+				if err != nil {
+					return nil, err
+				}
+				return Values{"dns": records}, nil
+			},
+		},
+
+		// http
+		{
+			name:        "httpx",
+			description: "Probes the surface over http. With ports, domains and ips are also probed on them, as host:port services",
+			inputs: []Socket{
+				{Name: "surface", Type: TypeSurface, Description: "the surface to probe"},
+				{Name: "ports", Type: TypePorts, Description: "the ports to probe on every domain and ip, besides the default ones", Optional: true},
+			},
+			outputs: []Socket{
+				{Name: "urls", Type: TypeURLs, Description: "the urls that responded"},
+				{Name: "services", Type: TypeServices, Description: "the services that responded"},
+				{Name: "http", Type: TypeHTTP, Description: "the responses of the urls"},
+			},
+			validate: validateHttpx,
+			execute:  executeHttpx,
+		},
+		{
+			name:        "httpx_wildcard",
+			description: "Probes the domains under a wildcard together with a random name under the same wildcard, and selects the ones whose response deviates from the catch-all one. The domains whose ips are all excluded are not probed",
+			inputs: []Socket{
+				{Name: "candidates", Type: TypeDomains, Description: "the domains under a wildcard"},
+				{Name: "wildcards", Type: TypeWildcards, Description: "the wildcards"},
+			},
+			outputs: []Socket{
+				{Name: "domains", Type: TypeDomains, Description: "the domains whose response deviates"},
+				{Name: "urls", Type: TypeURLs, Description: "the urls whose response deviates"},
+				{Name: "http", Type: TypeHTTP, Description: "the deviating responses"},
+				{Name: "excluded", Type: TypeDomains, Description: "the domains excluded by ip"},
+			},
+			validate: validateHttpxWildcard,
+			execute:  executeHttpxWildcard,
+		},
+		{
+			name:        "merge_http",
+			description: "Merges two sets of http responses. The second one wins on the urls in both",
+			inputs: []Socket{
+				{Name: "a", Type: TypeHTTP, Description: "the first set"},
+				{Name: "b", Type: TypeHTTP, Description: "the second set"},
+			},
+			outputs: []Socket{{Name: "http", Type: TypeHTTP, Description: "the merged responses"}},
+			execute: func(_ context.Context, _ *Env, _ Params, in Values) (Values, error) {
+				merged := maps.Clone(orEmptyMap(value[map[string]HTTPInfo](in, "a")))
+				maps.Copy(merged, value[map[string]HTTPInfo](in, "b"))
+				return Values{"http": merged}, nil
+			},
+		},
+
+		// issues
+		{
+			name:        "detect_takeovers",
+			description: "Detects the domains pointing to deprovisioned third-party resources, that can be claimed by anyone",
+			inputs: []Socket{
+				{Name: "dns", Type: TypeDNS, Description: "the dns records of the domains"},
+				{Name: "http", Type: TypeHTTP, Description: "the responses of the urls, searched for the takeover fingerprints. The domains without one are fetched again", Optional: true},
+			},
+			outputs:  []Socket{{Name: "issues", Type: TypeIssues, Description: "the takeover issues"}},
+			validate: validateHttpx,
+			execute: func(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+				fingerprints, err := TakeoverFingerprints()
+				if err != nil {
+					return nil, err
+				}
+				takeovers, err := DetectTakeovers(ctx, value[map[string]DNSRecords](in, "dns"), value[map[string]HTTPInfo](in, "http"),
+					fingerprints, NewBodyFetcher(env.Config.Httpx), env.Config.Httpx.Threads)
+				if err != nil {
+					return nil, err
+				}
+				return Values{"issues": takeovers}, nil
+			},
+		},
+	}
+}
+
+// insertOperator returns the operator that inserts a list of elements into a surface
+func insertOperator(dataType DataType, list string, excluded func(*Exclusions, string) bool, target func(*Surface) *[]string) *operator {
+	return &operator{
+		name:        "insert_" + list,
+		description: "Inserts " + list + " into a surface, dropping the excluded ones",
+		inputs: []Socket{
+			{Name: "surface", Type: TypeSurface, Description: "the surface to insert into"},
+			{Name: "items", Type: dataType, Description: "the " + list + " to insert"},
+		},
+		outputs: []Socket{{Name: "surface", Type: TypeSurface, Description: "the surface with the " + list}},
+		config:  []ConfigField{sourceParam},
+		execute: func(_ context.Context, env *Env, params Params, in Values) (Values, error) {
+			s := cloneSurface(value[Surface](in, "surface"))
+			provenance, source := sourceProvenance(env, params)
+			isExcluded := func(v string) bool { return excluded(env.Exclusions, v) }
+			insert_safe_string(value[[]string](in, "items"), isExcluded, target(&s), provenance, source)
+			return Values{"surface": s}, nil
+		},
+	}
+}
+
+func executeHttpx(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+	surface := value[Surface](in, "surface")
+	exclusions := env.Exclusions
+
+	targets, skipped := httpxTargets(surface, value[[]int](in, "ports"), exclusions)
+	if len(skipped) > 0 {
+		env.Logger.Warn("pipeline - networks too large to probe on custom ports, probing default ports only", "ips", skipped)
+	}
+
+	results, err := Httpx(ctx, targets, env.Config.Httpx)
+	if err != nil {
+		return nil, err
+	}
+	env.Logger.Info("httpx results", "results", results)
+
+	respondingURLs := []string{}
+	respondingServices := []string{}
+	httpInfo := map[string]HTTPInfo{}
+	for _, result := range results {
+		if result.Error == nil && result.URL != "" && !exclusions.Contains_url(result.URL) {
+			respondingURLs = append(respondingURLs, result.URL)
+			httpInfo[result.URL] = result.Info
+		}
+		if result.Error == nil && result.Service != "" {
+			respondingServices = append(respondingServices, result.Service)
+		}
+	}
+	return Values{"urls": respondingURLs, "services": respondingServices, "http": httpInfo}, nil
+}
+
+// httpxTargets returns the targets to probe on a surface. Domains and ips are probed on the
+// default ports, and on the custom ports as host:port services. It also returns the networks
+// too large to be expanded into services, that are probed on the default ports only
+func httpxTargets(surface Surface, ports []int, exclusions *Exclusions) (Surface, []string) {
+	targets := cloneSurface(surface)
+	if len(ports) == 0 {
+		return targets, nil
+	}
+	domainServices, _ := ExpandPorts(surface.Domains, ports)
+	ipServices, skipped := ExpandPorts(surface.IPs, ports)
+	insert_safe_string(domainServices, exclusions.Contains_service, &targets.Services, nil, "")
+	insert_safe_string(ipServices, exclusions.Contains_service, &targets.Services, nil, "")
+	return targets, skipped
+}
+
+func executeHttpxWildcard(ctx context.Context, env *Env, _ Params, in Values) (Values, error) {
+	exclusions := env.Exclusions
+	out := Values{"domains": []string{}, "urls": []string{}, "http": map[string]HTTPInfo{}, "excluded": []string{}}
+
+	// wildcards that answer only with excluded ips are not ours
+	activeWildcards := slices.DeleteFunc(slices.Clone(value[[]Wildcard](in, "wildcards")), func(w Wildcard) bool {
+		return exclusions.Contains_domain(w.Domain) || allIPsExcluded(w.IPs, exclusions)
+	})
+	var candidates []string
+	insert_safe_string(value[[]string](in, "candidates"), exclusions.Contains_domain, &candidates, nil, "")
+	candidates = SelectWildcardChildren(candidates, activeWildcards)
+	if len(candidates) == 0 {
+		return out, nil
+	}
+
+	// a child can have addresses of its own, instead of the ones of its wildcard:
+	// it is not probed when they are all excluded
+	if _, err := FilterActive(ctx, candidates, env.DNSCache, env.Resolver); err != nil {
+		return nil, err
+	}
+	candidates, excluded := ExcludeByIP(candidates, env.DNSCache, exclusions)
+	env.Logger.Info("pipeline - excluded by ip", "domains", excluded)
+	out["excluded"] = excluded
+	if len(candidates) == 0 {
+		return out, nil
+	}
+
+	results, err := HttpxWildcard(ctx, candidates, activeWildcards, env.Config.Httpx)
+	if err != nil {
+		return nil, err
+	}
+
+	var deviatingDomains []string
+	var deviatingURLs []string
+	httpInfo := map[string]HTTPInfo{}
+	for _, result := range results {
+		if exclusions.Contains_url(result.URL) {
+			continue
+		}
+		deviatingURLs = append(deviatingURLs, result.URL)
+		deviatingDomains = append(deviatingDomains, URLExtractDomains([]string{result.URL})...)
+		httpInfo[result.URL] = result.Info
+	}
+	env.Logger.Info("pipeline - wildcard deviations", "urls", deviatingURLs)
+	return Values{"domains": orEmpty(deviatingDomains), "urls": orEmpty(deviatingURLs), "http": httpInfo, "excluded": excluded}, nil
+}
+
+// orEmpty returns s, or an empty slice when s is nil
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// orEmptyMap returns m, or an empty map when m is nil
+func orEmptyMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return map[K]V{}
+	}
+	return m
+}
@@ -2,110 +2,142 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
 )
 
+// Discovery is the outcome of a surface discovery run
+type Discovery struct {
+	// Surface is the merged surface: everything in the known surface
+	// that is not excluded, plus everything discovered during the run.
+	Surface Surface
+	// Current is the part of the merged surface that was seen during the run.
+	// Known elements that were not discovered again are not part of it.
+	Current Surface
+	// Assets holds the provenance of every element of the merged surface
+	Assets map[string]Asset
+	// HTTP holds what was learned about every URL that responded during the run
+	HTTP map[string]HTTPInfo
+	// DNS holds the dns records of every domain seen during the run
+	DNS map[string]DNSRecords
+	// Wildcards are the declared and detected wildcards
+	Wildcards []Wildcard
+	// Issues are the issues detected on the current surface
+	Issues []issues.Issue
+	// ExcludedByIP are the discovered domains that were removed
+	// because all their IPs are excluded
+	ExcludedByIP []string
+}
+
+// RunSurfaceDiscovery expands the scope into the discoverable attack surface,
+// running the pipeline of the definition.
 func RunSurfaceDiscovery(
 	ctx context.Context,
 	logger *slog.Logger,
+	dnsCache *DNSCache,
 	knownSurface *Surface,
+	knownAssets map[string]Asset,
 	scope *Surface,
 	scopeExclusion *Surface,
-) {
-	// pipeline ideas:
-	// at the end of the discovery, resolve all domains to ips, one by one.
-	// if a domain matches with an excluded ip, add it to the exclusions
-	dnsCache := NewDNSCache()
-
-	exclusions := MakeExclusion()
-	exclusions.Insert(scopeExclusion)
-
-	pipeline := Surface{}
-	insert_safe(*knownSurface, exclusions, &pipeline)
-	insert_safe(*scope, exclusions, &pipeline)
-
-	logger.Info("pipeline - after insert", "domains", pipeline.Domains)
-
-	// expand scope from urls
-	{
-		extractedDomains := URLExtractDomains(pipeline.URLs)
-		insert_safe_string(extractedDomains, exclusions.Contains_domain, &pipeline.Domains)
-
-		extractedIPs := URLExtractIPs(pipeline.URLs)
-		insert_safe_string(extractedIPs, exclusions.Contains_ip, &pipeline.IPs)
+	config *Config,
+	definition *Definition,
+) (Discovery, error) {
+	// a broken pipeline must fail before any scanner runs
+	nodes, err := CompileSurfaceDiscovery(definition, config)
+	if err != nil {
+		return Discovery{}, fmt.Errorf("pipeline fail: %w", err)
 	}
 
-	// expand domains
-	{
-		// Remove subdomains of lower hierarchies before passing them to subfinder:
-		// if the list contains bb.a.example.com, cc.a.example.com and a.example.com
-		// we can assume that the whole a.example.com is in scope, and we can remove
-		// all subdomains of a.example.com from the list since they would return the
-		// same results
-		filteredDomains, err := TrimSubdomains(pipeline.Domains)
-		if err != nil {
-			logger.Error("filterSubdomains fail", "error", err)
-			return
-		}
-		logger.Info("pipeline - after filters", "domains", filteredDomains)
-
-		outDomains, err := Subfinder(ctx, filteredDomains)
-		if err != nil {
-			logger.Error("subfinder fail", "error", err)
-			return
-		}
-
-		insert_safe_string(outDomains, exclusions.Contains_domain, &pipeline.Domains)
-		logger.Info("pipeline - subfinder", "domains", outDomains)
+	env := NewEnv(logger, dnsCache, knownSurface, knownAssets, scope, scopeExclusion, config)
+	err = RunNodes(ctx, env, nodes, func(r NodeResult) {
+		logger.Info("pipeline - node", "id", r.Node.ID, "operator", r.Node.Operator, "outputs", CountValues(r.Outputs))
+	})
+	if err != nil {
+		return Discovery{}, err
 	}
+	return env.Discovery, nil
+}
 
-	wildcards := DnsxFilterWildcards(pipeline.Domains, dnsCache)
+// NodeResult is what a node read and wrote during a run
+type NodeResult struct {
+	Node     *Node
+	Inputs   Values
+	Outputs  Values
+	Duration time.Duration
+	Err      error
+}
 
-	//fuzzy search domains
-	{
-		//fuzzy generate domain names, based on alterx and LLM prompts
-		//insert into our dns pipeline only domains that resolve to something.
-		//even when domains resolve to something, make sure there are no wildcard dns
-		//to avoid false positives. it can be tested by resolving random strings
-		unfuzzableDomains := SelectSubdomains(pipeline.Domains, wildcards)
-		fuzzableDomains := Subtract(pipeline.Domains, unfuzzableDomains)
+// RunNodes executes compiled nodes, in order, over a new pipeline memory.
+// observe, when not nil, is called after every node, including the one that failed.
+// The run stops at the first error, or as soon as ctx is cancelled.
+func RunNodes(ctx context.Context, env *Env, nodes []Node, observe func(NodeResult)) error {
+	mem := NewMemory()
+	for i := range nodes {
+		node := &nodes[i]
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s fail: %w", node.ID, err)
+		}
 
-		fuzzDomains, err := Alterx(fuzzableDomains)
+		start := time.Now()
+		in, out, err := mem.Execute(ctx, env, node)
+		if observe != nil {
+			observe(NodeResult{Node: node, Inputs: in, Outputs: out, Duration: time.Since(start), Err: err})
+		}
 		if err != nil {
-			logger.Error("alterx fail", "error", err)
-			return
+			return fmt.Errorf("%s fail: %w", node.ID, err)
 		}
-		logger.Info("pipeline - after fuzz", "domains", fuzzDomains)
-
-		// exclude from our validation all fuzz domains that are part of wildcards domains,
-		// since they are untestable
-		logger.Info("pipeline - widcards", "domains", wildcards)
-		untestableFuzzDomains := SelectSubdomains(fuzzDomains, wildcards)
-		logger.Info("pipeline - untestable", "domains", untestableFuzzDomains)
-		fuzzDomains = Subtract(fuzzDomains, untestableFuzzDomains)
-		logger.Info("pipeline - testable", "domains", fuzzDomains)
-
-		// filter domains that resolve to an ip
-		validFuzzed := DnsxFilterActive(fuzzDomains, dnsCache)
-		logger.Info("pipeline - fuzz active dns", "domains", validFuzzed)
-		insert_safe_string(validFuzzed, exclusions.Contains_domain, &pipeline.Domains)
-
 	}
+	return nil
+}
 
-	// expand domains, ips, urls, list into active urls 
-	{
-		// we must run httpx two times: one with a surface set that only contains wildcard domains,
-		// and one with a surface set that does not contain wildcard domains.
-		// in NOwildcard mode, an http response is considered "discovered surface", and its url is put into the surface urls.
-		// in wildcard mode, all children of a specific wildcard are tested together, and only the domain 
-		// that receive a response deviating from the median response will be considered "discovered surface"
-		results, err := Httpx(pipeline, 2)
-		if err != nil {
-			logger.Error("alterx fail", "error", err)
-			return
+// CompileSurfaceDiscovery compiles a surface discovery pipeline.
+// On top of being valid, it must have exactly one discovery node, that collects its outcome
+func CompileSurfaceDiscovery(definition *Definition, config *Config) ([]Node, error) {
+	nodes, err := definition.Compile(config)
+	if err != nil {
+		return nil, err
+	}
+	sinks := 0
+	for _, node := range nodes {
+		if node.Operator == "discovery" {
+			sinks++
 		}
-		logger.Info("httpx results", "results", results)
+	}
+	if sinks != 1 {
+		return nil, fmt.Errorf("the pipeline must have exactly one discovery node, it has %d", sinks)
+	}
+	return nodes, nil
+}
 
+// CountValues returns the number of elements of every value
+func CountValues(values Values) map[string]int {
+	counts := map[string]int{}
+	for name, v := range values {
+		counts[name] = Count(v)
 	}
+	return counts
+}
 
+// Count returns the number of elements of a value of any DataType
+func Count(v any) int {
+	switch v := v.(type) {
+	case Surface:
+		return len(v.Domains) + len(v.IPs) + len(v.URLs) + len(v.Services)
+	case []string:
+		return len(v)
+	case []int:
+		return len(v)
+	case []Wildcard:
+		return len(v)
+	case map[string]HTTPInfo:
+		return len(v)
+	case map[string]DNSRecords:
+		return len(v)
+	case []issues.Issue:
+		return len(v)
+	}
+	return 0
 }
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+func TestRunNodes(t *testing.T) {
+	env := testEnv(Surface{})
+	env.Scope = Surface{Domains: []string{"example.com", "www.example.com"}}
+	nodes := []Node{
+		{ID: "scope", Operator: "scope"},
+		{ID: "split", Operator: "split_surface", Inputs: map[string]string{"surface": "scope.surface"}},
+	}
+
+	var observed []string
+	err := RunNodes(context.Background(), env, nodes, func(r NodeResult) {
+		observed = append(observed, r.Node.ID)
+		if r.Node.ID == "split" && Count(r.Outputs["domains"]) != 2 {
+			t.Errorf("expected 2 domains, got %v", r.Outputs["domains"])
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(observed) != 2 {
+		t.Errorf("expected every node to be observed, got %v", observed)
+	}
+
+	// a cancelled run stops before the next node
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	observed = nil
+	err = RunNodes(ctx, env, nodes, func(r NodeResult) { observed = append(observed, r.Node.ID) })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+	if len(observed) != 0 {
+		t.Errorf("expected no node to run, got %v", observed)
+	}
+}
+
+func TestDefaultPipelineExcludedWildcardChild(t *testing.T) {
+	config := DefaultConfig()
+	nodes, err := CompileSurfaceDiscovery(DefaultDefinition(), &config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// a child of *.wild.example.com, with an address of its own in an excluded network
+	env := testEnv(Surface{IPs: []string{"203.0.113.0/24"}})
+	env.DNSCache = NewDNSCache()
+	env.DNSCache.Set("own.wild.example.com", []string{"203.0.113.10"})
+
+	// the outputs of the nodes before the wildcard probes
+	mem := NewMemory()
+	for _, slot := range []struct {
+		name     string
+		dataType DataType
+		value    any
+	}{
+		{"split_fuzz.covered", TypeDomains, []string{"own.wild.example.com"}},
+		{"wildcards.wildcards", TypeWildcards, []Wildcard{{Domain: "wild.example.com", Depth: 1, IPs: []string{"192.0.2.1"}}}},
+		{"insert_httpx_services.surface", TypeSurface, Surface{Domains: []string{"wild.example.com"}}},
+		{"exclude_by_ip.excluded", TypeDomains, []string{}},
+		{"http.http", TypeHTTP, map[string]HTTPInfo{}},
+		{"dns_records.dns", TypeDNS, map[string]DNSRecords{}},
+		{"takeovers.issues", TypeIssues, []issues.Issue{}},
+	} {
+		if err := mem.Set(slot.name, slot.dataType, slot.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"httpx_wildcard", "insert_wildcard_domains", "insert_wildcard_urls", "excluded_by_ip", "discovery"} {
+		i := slices.IndexFunc(nodes, func(n Node) bool { return n.ID == id })
+		if i < 0 {
+			t.Fatalf("node %s not found in the default pipeline", id)
+		}
+		if _, _, err := mem.Execute(context.Background(), env, &nodes[i]); err != nil {
+			t.Fatalf("%s fail: %v", id, err)
+		}
+	}
+
+	if slices.Contains(env.Discovery.Surface.Domains, "own.wild.example.com") {
+		t.Errorf("Expected the excluded child to be dropped, got %v", env.Discovery.Surface.Domains)
+	}
+	if !slices.Contains(env.Discovery.ExcludedByIP, "own.wild.example.com") {
+		t.Errorf("Expected the child to be excluded by ip, got %v", env.Discovery.ExcludedByIP)
+	}
+}
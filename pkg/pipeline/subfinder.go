@@ -15,15 +15,16 @@ import (
 func Subfinder(
 	ctx context.Context,
 	domains []string,
+	config SubfinderConfig,
 ) ([]string, error) {
 	if len(domains) == 0 {
 		return []string{}, nil
 	}
 
 	subfinderOpts := &runner.Options{
-		Threads:            5,
-		Timeout:            10,
-		MaxEnumerationTime: 30,
+		Threads:            config.Threads,
+		Timeout:            config.Timeout,
+		MaxEnumerationTime: config.MaxEnumerationTime,
 		Silent:             false,
 	}
 
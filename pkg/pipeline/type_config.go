@@ -0,0 +1,179 @@
+package pipeline
+
+import "fmt"
+
+// Config holds the tuning parameters of every pipeline stage
+type Config struct {
+	Subfinder SubfinderConfig `yaml:"subfinder"`
+	Alterx    AlterxConfig    `yaml:"alterx"`
+	Httpx     HttpxConfig     `yaml:"httpx"`
+	DNS       DNSConfig       `yaml:"dns"`
+}
+
+type SubfinderConfig struct {
+	// Threads is the number of threads used for active enumerations
+	Threads int `yaml:"threads"`
+	// Timeout is the number of seconds to wait for a source to respond
+	Timeout int `yaml:"timeout"`
+	// MaxEnumerationTime is the number of minutes to wait for the enumeration of a domain
+	MaxEnumerationTime int `yaml:"max_enumeration_time"`
+}
+
+type AlterxConfig struct {
+	// MaxSize is the maximum number of domain permutations generated
+	MaxSize int `yaml:"max_size"`
+	// Enrich enables the extraction of words from the input domains,
+	// to use them in the permutations
+	Enrich bool `yaml:"enrich"`
+}
+
+type HttpxConfig struct {
+	// Threads is the number of concurrent http probes
+	Threads int `yaml:"threads"`
+	// Timeout is the number of seconds to wait for a response
+	Timeout int `yaml:"timeout"`
+	// Retries is the number of retries for a failed probe
+	Retries int `yaml:"retries"`
+	// RateLimit is the maximum number of http requests per second
+	RateLimit int `yaml:"rate_limit"`
+}
+
+type DNSConfig struct {
+	// Threads is the number of concurrent dns queries
+	Threads int `yaml:"threads"`
+	// RateLimit is the maximum number of dns queries per second
+	RateLimit int `yaml:"rate_limit"`
+	// Retries is the number of retries for a failed query
+	Retries int `yaml:"retries"`
+	// Timeout is the number of seconds to wait for the answer to a query
+	Timeout int `yaml:"timeout"`
+	// Resolvers are the dns servers to query, in the format ip or ip:port.
+	// When empty, a list of public resolvers is used
+	Resolvers []string `yaml:"resolvers"`
+	// TrustedResolvers, when set, are queried again for every domain that resolved,
+	// and have the final word on its IPs
+	TrustedResolvers []string `yaml:"trusted_resolvers"`
+	// Cache enables the persistence of the dns cache in the data folder, across runs.
+	// Positive answers are cached for the TTL of their records
+	Cache bool `yaml:"cache"`
+	// NegativeTTL is the number of seconds a domain that does not exist, or has no address, stays cached
+	NegativeTTL int `yaml:"negative_ttl"`
+	// WildcardProbes is the number of random names resolved to tell if a domain is a wildcard.
+	// A domain is a wildcard when most of them resolve
+	WildcardProbes int `yaml:"wildcard_probes"`
+	// WildcardDepth is the maximum number of random labels in the probes:
+	// with 2, *.*.example.com wildcards are detected too
+	WildcardDepth int `yaml:"wildcard_depth"`
+}
+
+// DefaultConfig returns the configuration used for everything
+// that is not set in the configuration file
+func DefaultConfig() Config {
+	return Config{
+		Subfinder: SubfinderConfig{
+			Threads:            5,
+			Timeout:            10,
+			MaxEnumerationTime: 30,
+		},
+		Alterx: AlterxConfig{
+			MaxSize: 1000,
+			Enrich:  true,
+		},
+		Httpx: HttpxConfig{
+			Threads:   2,
+			Timeout:   10,
+			Retries:   0,
+			RateLimit: 150,
+		},
+		DNS: DNSConfig{
+			Threads:        50,
+			RateLimit:      200,
+			Retries:        2,
+			Timeout:        3,
+			NegativeTTL:    3600,
+			WildcardProbes: 3,
+			WildcardDepth:  2,
+		},
+	}
+}
+
+// Validate checks that every parameter is within its allowed range
+func (c *Config) Validate() error {
+	for _, err := range []error{
+		c.Subfinder.Validate(),
+		c.Alterx.Validate(),
+		c.Httpx.Validate(),
+		c.DNS.Validate(),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *SubfinderConfig) Validate() error {
+	return checkRanges([]rangeCheck{
+		{"subfinder.threads", c.Threads, 1, 100},
+		{"subfinder.timeout", c.Timeout, 1, 300},
+		{"subfinder.max_enumeration_time", c.MaxEnumerationTime, 1, 600},
+	})
+}
+
+func (c *AlterxConfig) Validate() error {
+	return checkRanges([]rangeCheck{
+		{"alterx.max_size", c.MaxSize, 1, 1000000},
+	})
+}
+
+func (c *HttpxConfig) Validate() error {
+	return checkRanges([]rangeCheck{
+		{"httpx.threads", c.Threads, 1, 500},
+		{"httpx.timeout", c.Timeout, 1, 300},
+		{"httpx.retries", c.Retries, 0, 10},
+		{"httpx.rate_limit", c.RateLimit, 1, 10000},
+	})
+}
+
+func (c *DNSConfig) Validate() error {
+	err := checkRanges([]rangeCheck{
+		{"dns.threads", c.Threads, 1, 1000},
+		{"dns.rate_limit", c.RateLimit, 1, 100000},
+		{"dns.retries", c.Retries, 0, 10},
+		{"dns.timeout", c.Timeout, 1, 60},
+		{"dns.negative_ttl", c.NegativeTTL, 1, 604800},
+		{"dns.wildcard_probes", c.WildcardProbes, 1, 20},
+		{"dns.wildcard_depth", c.WildcardDepth, 1, 5},
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, resolver := range c.Resolvers {
+		if _, err := resolverAddress(resolver); err != nil {
+			return fmt.Errorf("'dns.resolvers' has an invalid resolver at index %d: %w", i, err)
+		}
+	}
+	for i, resolver := range c.TrustedResolvers {
+		if _, err := resolverAddress(resolver); err != nil {
+			return fmt.Errorf("'dns.trusted_resolvers' has an invalid resolver at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+type rangeCheck struct {
+	name  string
+	value int
+	min   int
+	max   int
+}
+
+func checkRanges(checks []rangeCheck) error {
+	for _, check := range checks {
+		if check.value < check.min || check.value > check.max {
+			return fmt.Errorf("'%s' must be between %d and %d, got %d", check.name, check.min, check.max, check.value)
+		}
+	}
+	return nil
+}
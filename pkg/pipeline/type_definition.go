@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default_pipeline.yaml
+var defaultPipelineFile []byte
+
+// Definition is a declarative pipeline: a list of operator nodes,
+// and the edges that connect their outputs to their inputs
+type Definition struct {
+	Name  string           `yaml:"name,omitempty" json:"name,omitempty"`
+	Nodes []NodeDefinition `yaml:"nodes" json:"nodes"`
+	Edges []Edge           `yaml:"edges" json:"edges"`
+}
+
+// NodeDefinition is a node of a pipeline definition.
+// Its inputs are set by the edges of the definition
+type NodeDefinition struct {
+	ID       string `yaml:"id" json:"id"`
+	Operator string `yaml:"operator" json:"operator"`
+	Params   Params `yaml:"params,omitempty" json:"params,omitempty"`
+	// Position is where the node is drawn in the web editor. It has no effect on the run
+	Position *Position `yaml:"position,omitempty" json:"position,omitempty"`
+}
+
+// Position is a point in the web editor canvas
+type Position struct {
+	X float64 `yaml:"x" json:"x"`
+	Y float64 `yaml:"y" json:"y"`
+}
+
+// Edge connects the output of a node to the input of another one.
+// Both ends are in the node.socket notation
+type Edge struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+}
+
+// ParseDefinition parses a pipeline definition, in yaml or json
+func ParseDefinition(data []byte) (*Definition, error) {
+	var definition Definition
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&definition); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Invalid Syntax: %w", err)
+	}
+	return &definition, nil
+}
+
+// DefaultDefinition returns the built-in surface discovery pipeline
+func DefaultDefinition() *Definition {
+	definition, err := ParseDefinition(defaultPipelineFile)
+	if err != nil {
+		panic(fmt.Sprintf("the built-in pipeline definition is invalid: %v", err))
+	}
+	return definition
+}
+
+// Compile checks the definition, and turns it into the list of its nodes
+// in execution order.
+// Every edge must connect an existing output to an existing input of the same type,
+// every input can be connected at most once, and the graph must have no cycles.
+func (d *Definition) Compile(config *Config) ([]Node, error) {
+	if len(d.Nodes) == 0 {
+		return nil, fmt.Errorf("the pipeline has no nodes")
+	}
+
+	nodes := make([]Node, len(d.Nodes))
+	index := map[string]int{}
+	for i, n := range d.Nodes {
+		if n.ID == "" || strings.Contains(n.ID, ".") {
+			return nil, fmt.Errorf("node at index %d has an invalid id '%s'", i, n.ID)
+		}
+		if _, exists := index[n.ID]; exists {
+			return nil, fmt.Errorf("duplicate node id '%s'", n.ID)
+		}
+		index[n.ID] = i
+		nodes[i] = Node{ID: n.ID, Operator: n.Operator, Params: n.Params, Inputs: map[string]string{}}
+	}
+
+	// the operators must exist before their sockets can be checked
+	for i := range nodes {
+		if _, exists := LookupOperator(nodes[i].Operator); !exists {
+			return nil, fmt.Errorf("node %s: unknown operator '%s'", nodes[i].ID, nodes[i].Operator)
+		}
+	}
+
+	// dependencies[i] are the indexes of the nodes whose outputs node i reads
+	dependencies := make([][]int, len(nodes))
+	for _, e := range d.Edges {
+		fromID, output, _ := strings.Cut(e.From, ".")
+		toID, input, _ := strings.Cut(e.To, ".")
+		from, fromExists := index[fromID]
+		to, toExists := index[toID]
+		if !fromExists || output == "" {
+			return nil, fmt.Errorf("edge %s -> %s: unknown output '%s'", e.From, e.To, e.From)
+		}
+		if !toExists || input == "" {
+			return nil, fmt.Errorf("edge %s -> %s: unknown input '%s'", e.From, e.To, e.To)
+		}
+
+		fromOp, _ := LookupOperator(nodes[from].Operator)
+		toOp, _ := LookupOperator(nodes[to].Operator)
+		outSocket, found := findSocket(fromOp.Outputs(), output)
+		if !found {
+			return nil, fmt.Errorf("edge %s -> %s: operator %s has no output '%s'", e.From, e.To, fromOp.Name(), output)
+		}
+		inSocket, found := findSocket(toOp.Inputs(), input)
+		if !found {
+			return nil, fmt.Errorf("edge %s -> %s: operator %s has no input '%s'", e.From, e.To, toOp.Name(), input)
+		}
+		if outSocket.Type != inSocket.Type {
+			return nil, fmt.Errorf("edge %s -> %s: cannot connect an output of type %s to an input of type %s", e.From, e.To, outSocket.Type, inSocket.Type)
+		}
+		if connected, exists := nodes[to].Inputs[input]; exists {
+			return nil, fmt.Errorf("edge %s -> %s: input is already connected to %s", e.From, e.To, connected)
+		}
+
+		nodes[to].Inputs[input] = nodes[from].Slot(output)
+		dependencies[to] = append(dependencies[to], from)
+	}
+
+	for i := range nodes {
+		if err := nodes[i].Validate(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return sortNodes(nodes, dependencies)
+}
+
+// sortNodes sorts the nodes topologically.
+// The sort is stable: among the nodes that are ready to run, the one listed first runs first.
+// Operators share state through the Env, so a definition already in a valid
+// order runs exactly in the order it was written.
+func sortNodes(nodes []Node, dependencies [][]int) ([]Node, error) {
+	done := make([]bool, len(nodes))
+	sorted := make([]Node, 0, len(nodes))
+	for len(sorted) < len(nodes) {
+		next := -1
+		for i := range nodes {
+			if !done[i] && allDone(dependencies[i], done) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var blocked []string
+			for i := range nodes {
+				if !done[i] {
+					blocked = append(blocked, nodes[i].ID)
+				}
+			}
+			return nil, fmt.Errorf("the pipeline has a cycle, these nodes can never run: %v", blocked)
+		}
+		done[next] = true
+		sorted = append(sorted, nodes[next])
+	}
+	return sorted, nil
+}
+
+func allDone(indexes []int, done []bool) bool {
+	for _, i := range indexes {
+		if !done[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func nodeIDs(nodes []Node) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func TestDefaultDefinition(t *testing.T) {
+	config := DefaultConfig()
+	definition := DefaultDefinition()
+	nodes, err := CompileSurfaceDiscovery(definition, &config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the built-in pipeline is written in a valid order, and must run in that order
+	var expected []string
+	for _, n := range definition.Nodes {
+		expected = append(expected, n.ID)
+	}
+	if got := nodeIDs(nodes); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected execution order %v, got %v", expected, got)
+	}
+}
+
+func TestDefaultDefinitionReversed(t *testing.T) {
+	// graphs exported from the editor list the nodes in creation order:
+	// the dependencies must hold whatever the order
+	config := DefaultConfig()
+	definition := DefaultDefinition()
+	for i, j := 0, len(definition.Nodes)-1; i < j; i, j = i+1, j-1 {
+		definition.Nodes[i], definition.Nodes[j] = definition.Nodes[j], definition.Nodes[i]
+	}
+	nodes, err := CompileSurfaceDiscovery(definition, &config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	position := map[string]int{}
+	for i, id := range nodeIDs(nodes) {
+		position[id] = i
+	}
+	// the edges are the only dependencies between the nodes
+	for _, edge := range definition.Edges {
+		from, _, _ := strings.Cut(edge.From, ".")
+		to, _, _ := strings.Cut(edge.To, ".")
+		if position[from] > position[to] {
+			t.Errorf("%s runs before %s: %v", to, from, nodeIDs(nodes))
+		}
+	}
+}
+
+func TestCompileDefinition(t *testing.T) {
+	tests := []struct {
+		name        string
+		definition  string
+		expected    []string
+		errContains string
+	}{
+		{
+			name: "sorted",
+			definition: `
+nodes:
+  - {id: resolve, operator: resolve}
+  - {id: split, operator: split_surface}
+  - {id: scope, operator: scope}
+  - {id: trim, operator: trim_subdomains}
+edges:
+  - {from: split.domains, to: resolve.domains}
+  - {from: scope.surface, to: split.surface}
+  - {from: split.domains, to: trim.domains}
+`,
+			expected: []string{"scope", "split", "resolve", "trim"},
+		},
+		{
+			name: "json",
+			definition: `{"name": "json", "nodes": [
+  {"id": "scope", "operator": "scope"},
+  {"id": "insert", "operator": "insert_domains", "params": {"source": "dns"}}
+], "edges": [
+  {"from": "scope.surface", "to": "insert.surface"},
+  {"from": "scope.surface", "to": "insert.items"}
+]}`,
+			errContains: "cannot connect an output of type surface to an input of type domains",
+		},
+		{
+			name:        "no nodes",
+			definition:  `name: empty`,
+			errContains: "the pipeline has no nodes",
+		},
+		{
+			name:        "unknown field",
+			definition:  "nodes:\n  - {id: a, operator: scope, input: x}\n",
+			errContains: "Invalid Syntax",
+		},
+		{
+			name:        "duplicate id",
+			definition:  "nodes:\n  - {id: a, operator: scope}\n  - {id: a, operator: known_surface}\n",
+			errContains: "duplicate node id 'a'",
+		},
+		{
+			name:        "invalid id",
+			definition:  "nodes:\n  - {id: a.b, operator: scope}\n",
+			errContains: "invalid id 'a.b'",
+		},
+		{
+			name:        "unknown operator",
+			definition:  "nodes:\n  - {id: a, operator: nmap}\n",
+			errContains: "unknown operator 'nmap'",
+		},
+		{
+			name: "unknown node",
+			definition: `
+nodes:
+  - {id: a, operator: split_surface}
+edges:
+  - {from: b.surface, to: a.surface}
+`,
+			errContains: "unknown output 'b.surface'",
+		},
+		{
+			name: "unknown socket",
+			definition: `
+nodes:
+  - {id: scope, operator: scope}
+  - {id: a, operator: split_surface}
+edges:
+  - {from: scope.domains, to: a.surface}
+`,
+			errContains: "operator scope has no output 'domains'",
+		},
+		{
+			name: "input connected twice",
+			definition: `
+nodes:
+  - {id: scope, operator: scope}
+  - {id: known, operator: known_surface}
+  - {id: a, operator: split_surface}
+edges:
+  - {from: scope.surface, to: a.surface}
+  - {from: known.surface, to: a.surface}
+`,
+			errContains: "input is already connected to scope.surface",
+		},
+		{
+			name: "input not connected",
+			definition: `
+nodes:
+  - {id: a, operator: split_surface}
+`,
+			errContains: "input 'surface' is not connected",
+		},
+		{
+			name: "invalid params",
+			definition: `
+nodes:
+  - {id: scope, operator: scope}
+  - {id: known, operator: known_surface}
+  - {id: merge, operator: merge, params: {source: shodan}}
+edges:
+  - {from: scope.surface, to: merge.a}
+  - {from: known.surface, to: merge.b}
+`,
+			errContains: "parameter 'source' must be one of",
+		},
+		{
+			name: "cycle",
+			definition: `
+nodes:
+  - {id: scope, operator: scope}
+  - {id: a, operator: insert_domains}
+  - {id: split, operator: split_surface}
+edges:
+  - {from: scope.surface, to: a.surface}
+  - {from: split.domains, to: a.items}
+  - {from: a.surface, to: split.surface}
+`,
+			errContains: "these nodes can never run: [a split]",
+		},
+	}
+
+	config := DefaultConfig()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := func() ([]Node, error) {
+				definition, err := ParseDefinition([]byte(tt.definition))
+				if err != nil {
+					return nil, err
+				}
+				return definition.Compile(&config)
+			}()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := nodeIDs(nodes); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected order %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompileSurfaceDiscovery(t *testing.T) {
+	config := DefaultConfig()
+	definition, err := ParseDefinition([]byte("nodes:\n  - {id: scope, operator: scope}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CompileSurfaceDiscovery(definition, &config)
+	if err == nil || !strings.Contains(err.Error(), "exactly one discovery node, it has 0") {
+		t.Fatalf("expected a missing discovery node error, got %v", err)
+	}
+}
@@ -2,32 +2,90 @@ package pipeline
 
 import (
 	"sync"
+	"time"
 )
 
+// DNSCacheEntry is a cached resolution.
+// An entry without an expiry is only valid for the current run.
+type DNSCacheEntry struct {
+	IPs     []string  `yaml:"ips"`
+	Expires time.Time `yaml:"expires"`
+}
+
 type DNSCache struct {
-	cache map[string][]string
+	cache map[string]DNSCacheEntry
 	mutex sync.RWMutex
+	now   func() time.Time
 }
 
 func NewDNSCache() *DNSCache {
 	return &DNSCache{
-		cache: make(map[string][]string),
+		cache: make(map[string]DNSCacheEntry),
+		now:   time.Now,
 	}
 }
 
-// Get returns cached IPs for a domain if they exist
+// Get returns cached IPs for a domain if they exist, and have not expired
 func (c *DNSCache) Get(domain string) ([]string, bool) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	ips, exists := c.cache[domain]
-	return ips, exists
+	entry, exists := c.cache[domain]
+	if !exists || c.expired(entry) {
+		return nil, false
+	}
+	return entry.IPs, true
 }
 
-// Set caches the IPs for a domain
+// Set caches the IPs for a domain, for the current run only
 func (c *DNSCache) Set(domain string, ips []string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.cache[domain] = ips
+	c.cache[domain] = DNSCacheEntry{IPs: ips}
+}
+
+// SetWithTTL caches the IPs for a domain, until the ttl expires
+func (c *DNSCache) SetWithTTL(domain string, ips []string, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.cache[domain] = DNSCacheEntry{
+		IPs:     ips,
+		Expires: c.now().Add(ttl).UTC().Truncate(time.Second),
+	}
+}
+
+// Load adds to the cache the entries persisted by a previous run.
+// Expired entries, and entries without an expiry, are discarded.
+func (c *DNSCache) Load(entries map[string]DNSCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for domain, entry := range entries {
+		if entry.Expires.IsZero() || c.expired(entry) {
+			continue
+		}
+		c.cache[domain] = entry
+	}
+}
+
+// Entries returns the entries that can be persisted for the next runs:
+// the ones with an expiry that has not passed yet
+func (c *DNSCache) Entries() map[string]DNSCacheEntry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entries := make(map[string]DNSCacheEntry)
+	for domain, entry := range c.cache {
+		if entry.Expires.IsZero() || c.expired(entry) {
+			continue
+		}
+		entries[domain] = entry
+	}
+	return entries
+}
+
+func (c *DNSCache) expired(entry DNSCacheEntry) bool {
+	return !entry.Expires.IsZero() && !c.now().Before(entry.Expires)
 }
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDNSCacheExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache := NewDNSCache()
+	cache.now = func() time.Time { return now }
+
+	cache.Set("run.example.com", []string{"192.0.2.1"})
+	cache.SetWithTTL("long.example.com", []string{"192.0.2.2"}, time.Hour)
+	cache.SetWithTTL("nxdomain.example.com", []string{}, 10*time.Minute)
+
+	for _, domain := range []string{"run.example.com", "long.example.com", "nxdomain.example.com"} {
+		if _, found := cache.Get(domain); !found {
+			t.Errorf("Expected %s to be cached", domain)
+		}
+	}
+
+	// entries without an expiry are not persisted
+	entries := cache.Entries()
+	expected := map[string]DNSCacheEntry{
+		"long.example.com":     {IPs: []string{"192.0.2.2"}, Expires: now.Add(time.Hour)},
+		"nxdomain.example.com": {IPs: []string{}, Expires: now.Add(10 * time.Minute)},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Entries mismatch.\nExpected: %v\nGot: %v", expected, entries)
+	}
+
+	// the negative entry expires first. Entries for the current run never do
+	now = now.Add(30 * time.Minute)
+	if _, found := cache.Get("nxdomain.example.com"); found {
+		t.Errorf("Expected the negative entry to be expired")
+	}
+	if _, found := cache.Get("long.example.com"); !found {
+		t.Errorf("Expected long.example.com to be cached")
+	}
+	if _, found := cache.Get("run.example.com"); !found {
+		t.Errorf("Expected run.example.com to be cached")
+	}
+
+	// a new run only loads what did not expire
+	next := NewDNSCache()
+	next.now = func() time.Time { return now }
+	next.Load(entries)
+	if !reflect.DeepEqual(next.Entries(), map[string]DNSCacheEntry{"long.example.com": expected["long.example.com"]}) {
+		t.Errorf("Unexpected loaded entries: %v", next.Entries())
+	}
+}
@@ -0,0 +1,25 @@
+package pipeline
+
+// DNSRecords holds the dns records of a single domain.
+// Every list is sorted, except for CNAME, which is in resolution order.
+type DNSRecords struct {
+	// CNAME is the chain of aliases, from the domain to its canonical name
+	CNAME []string `yaml:"cname,omitempty"`
+	A     []string `yaml:"a,omitempty"`
+	AAAA  []string `yaml:"aaaa,omitempty"`
+	NS    []string `yaml:"ns,omitempty"`
+	// MX records are in the format "preference host"
+	MX  []string `yaml:"mx,omitempty"`
+	TXT []string `yaml:"txt,omitempty"`
+	// NXDOMAIN is set when the domain, or the last name of its CNAME chain, does not exist
+	NXDOMAIN bool `yaml:"nxdomain,omitempty"`
+}
+
+// CanonicalName returns the last name of the CNAME chain,
+// or an empty string when the domain is not an alias
+func (r DNSRecords) CanonicalName() string {
+	if len(r.CNAME) == 0 {
+		return ""
+	}
+	return r.CNAME[len(r.CNAME)-1]
+}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Env is the state of a run, shared by all its operators
+type Env struct {
+	Logger *slog.Logger
+	Config *Config
+	// Known is the surface known from the previous runs
+	Known Surface
+	// Scope is the scope, and the surface that must never be discovered
+	Scope          Surface
+	ScopeExclusion Surface
+	// Exclusions grow during the run, with the domains excluded by ip
+	Exclusions *Exclusions
+	// Provenance tracks what was seen during the run, and by which source
+	Provenance *Provenance
+	DNSCache   *DNSCache
+	Resolver   *Resolver
+	// Discovery is the outcome of the run, set by the discovery operator
+	Discovery Discovery
+}
+
+// NewEnv initializes the state of a run
+func NewEnv(
+	logger *slog.Logger,
+	dnsCache *DNSCache,
+	knownSurface *Surface,
+	knownAssets map[string]Asset,
+	scope *Surface,
+	scopeExclusion *Surface,
+	config *Config,
+) *Env {
+	exclusions := MakeExclusion()
+	exclusions.Insert(scopeExclusion)
+
+	return &Env{
+		Logger:         logger,
+		Config:         config,
+		Known:          *knownSurface,
+		Scope:          *scope,
+		ScopeExclusion: *scopeExclusion,
+		Exclusions:     &exclusions,
+		// Everything inserted into the pipeline with a provenance is marked as seen in this run.
+		// The known surface is inserted without one: it must be discovered again to be seen.
+		Provenance: NewProvenance(knownAssets, time.Now()),
+		DNSCache:   dnsCache,
+		Resolver:   NewResolver(config.DNS),
+	}
+}
@@ -1,68 +1,264 @@
 package pipeline
 
-import "strings"
+import (
+	"net"
+	"net/netip"
+	"path"
+	"strings"
 
+	"github.com/gaissmai/bart"
+	"github.com/robalb/tinyasm/pkg/iprange"
+)
+
+// Domain exclusions support the following syntax:
+//   - example.com    excludes example.com, and all its subdomains
+//   - =example.com   excludes only example.com
+//   - *.example.com  excludes all the subdomains of example.com, but not example.com.
+//     A * matches a single label, and can be combined with other characters, as in cdn-*.example.com
+//
+// URL exclusions exclude all the URLs with the same scheme, host and port, and a path
+// equal to or under the excluded one:
+//   - https://example.com/admin    excludes https://example.com/admin/users, but not https://example.com/administrator
+//   - example.com/admin            without a scheme, excludes both the http and the https URLs
+//   - https://example.com:8443     without a path, excludes everything on the host and port
+//   - https://example.com/*/admin  the path can contain wildcards. A * does not match a /
+//
+// Service exclusions exclude a single host:port. A service is also excluded when its host is,
+// or when a URL exclusion without a path covers its host and port.
 type Exclusions struct {
+	// Domains holds the domains excluded with all their subdomains
 	Domains map[string]struct{}
-	IPs     map[string]struct{}
-	URLs    map[string]struct{}
-}
-
-func normalize_domain(domain string) string {
-	return strings.ToLower(domain)
+	// ExactDomains holds the domains excluded without their subdomains
+	ExactDomains map[string]struct{}
+	// DomainPatterns holds the wildcard patterns, split into labels
+	DomainPatterns []domainPattern
+	// IPs holds every excluded address, CIDR and range, as a prefix table
+	IPs *bart.Lite
+	// URLs holds the normalized url exclusions
+	URLs []urlPattern
+	// Services holds the normalized host:port exclusions
+	Services map[string]struct{}
 }
 
-func normalize_ip(ip string) string {
-	return ip // For now, no normalization needed for IPs
+type domainPattern struct {
+	labels []string
+	exact  bool
 }
 
-func normalize_url(url string) string {
-	return strings.ToLower(url)
+func normalize_domain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
 }
 
 // MakeExclusion initializes a new Exclusions struct
 func MakeExclusion() Exclusions {
 	return Exclusions{
-		Domains: make(map[string]struct{}),
-		IPs:     make(map[string]struct{}),
-		URLs:    make(map[string]struct{}),
+		Domains:      make(map[string]struct{}),
+		ExactDomains: make(map[string]struct{}),
+		IPs:          &bart.Lite{},
+		URLs:         []urlPattern{},
+		Services:     make(map[string]struct{}),
 	}
 }
 
 // Insert adds all elements from the given Surface to the Exclusions
 func (e *Exclusions) Insert(s *Surface) {
 	for _, domain := range s.Domains {
-		e.Domains[normalize_domain(domain)] = struct{}{}
+		domain = strings.TrimSpace(domain)
+		exact := strings.HasPrefix(domain, "=")
+		domain = normalize_domain(strings.TrimPrefix(domain, "="))
+
+		switch {
+		case strings.Contains(domain, "*"):
+			e.DomainPatterns = append(e.DomainPatterns, domainPattern{
+				labels: strings.Split(domain, "."),
+				exact:  exact,
+			})
+		case exact:
+			e.ExactDomains[domain] = struct{}{}
+		default:
+			e.Domains[domain] = struct{}{}
+		}
 	}
 
 	for _, ip := range s.IPs {
-		e.IPs[normalize_ip(ip)] = struct{}{}
+		// invalid values are rejected when parsing the configuration files
+		prefixes, err := iprange.Parse(ip)
+		if err != nil {
+			continue
+		}
+		for _, prefix := range prefixes {
+			e.IPs.Insert(prefix)
+		}
 	}
 
 	for _, url := range s.URLs {
-		e.URLs[normalize_url(url)] = struct{}{}
+		// invalid values are rejected when parsing the configuration files
+		pattern, err := parseURLPattern(url)
+		if err != nil {
+			continue
+		}
+		e.URLs = append(e.URLs, pattern)
+	}
+
+	for _, service := range s.Services {
+		// invalid values are rejected when parsing the configuration files
+		host, port, err := splitService(service)
+		if err != nil {
+			continue
+		}
+		e.Services[net.JoinHostPort(host, port)] = struct{}{}
 	}
 }
 
-// Contains_domain checks if a domain is in the exclusions
+// Contains_domain checks if a domain is in the exclusions,
+// either directly or because one of its parents is
 func (e *Exclusions) Contains_domain(domain string) bool {
-	_, exists := e.Domains[normalize_domain(domain)]
+	domain = normalize_domain(domain)
+
+	if _, exists := e.ExactDomains[domain]; exists {
+		return true
+	}
+
+	// check the domain, and all its parents
+	for parent := domain; parent != ""; {
+		if _, exists := e.Domains[parent]; exists {
+			return true
+		}
+		_, parent, _ = strings.Cut(parent, ".")
+	}
+
+	labels := strings.Split(domain, ".")
+	for _, pattern := range e.DomainPatterns {
+		if pattern.matches(labels) {
+			return true
+		}
+	}
+
+	return false
+}
 
-	//TODO: if domain is not a TLD, check if its parent
-	//      is in the exclusion list.
-	return exists
+// matches checks if the pattern matches the domain, or one of its parents
+// when the pattern is not exact
+func (p domainPattern) matches(labels []string) bool {
+	if len(labels) < len(p.labels) || (p.exact && len(labels) != len(p.labels)) {
+		return false
+	}
+
+	// compare the pattern with the rightmost labels of the domain
+	offset := len(labels) - len(p.labels)
+	for i, patternLabel := range p.labels {
+		matched, err := path.Match(patternLabel, labels[offset+i])
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
 }
 
-// Contains_ip checks if an IP is in the exclusions
+// Contains_ip checks if an IP is in the exclusions.
+// CIDRs and ranges are in the exclusions only if all their addresses are.
 func (e *Exclusions) Contains_ip(ip string) bool {
-	_, exists := e.IPs[normalize_ip(ip)]
-	return exists
+	prefixes, err := iprange.Parse(ip)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range prefixes {
+		// longest prefix match: finds an excluded prefix that covers the whole prefix
+		if _, covered := e.IPs.LookupPrefix(prefix); !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// Trim_ips returns the given ips without the excluded addresses.
+// IPs, CIDRs and ranges entirely excluded are removed.
+// CIDRs and ranges partially excluded are split into the CIDRs that are not.
+// Ranges are always converted into CIDRs.
+func (e *Exclusions) Trim_ips(ips []string) []string {
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		prefixes, err := iprange.Parse(ip)
+		if err != nil {
+			continue
+		}
+
+		var trimmed []netip.Prefix
+		for _, prefix := range prefixes {
+			trimmed = append(trimmed, e.trimPrefix(prefix)...)
+		}
+
+		// values that do not need to change are kept as they are
+		if len(trimmed) == 1 && len(prefixes) == 1 && trimmed[0] == prefixes[0] && !strings.Contains(ip, "-") {
+			result = append(result, ip)
+			continue
+		}
+		for _, prefix := range trimmed {
+			result = append(result, iprange.Format(prefix))
+		}
+	}
+	return result
+}
+
+// trimPrefix returns the list of prefixes that covers all the
+// addresses of prefix that are not excluded
+func (e *Exclusions) trimPrefix(prefix netip.Prefix) []netip.Prefix {
+	if _, covered := e.IPs.LookupPrefix(prefix); covered {
+		return nil
+	}
+	if !e.IPs.OverlapsPrefix(prefix) {
+		return []netip.Prefix{prefix}
+	}
+
+	// partially excluded: split the prefix in two halves, and trim them.
+	// A single address can't be partially excluded, so this always terminates
+	low := netip.PrefixFrom(prefix.Addr(), prefix.Bits()+1)
+	high := netip.PrefixFrom(iprange.LastAddr(low).Next(), prefix.Bits()+1)
+	return append(e.trimPrefix(low), e.trimPrefix(high)...)
 }
 
-// Contains_url checks if a URL is in the exclusions
+// Contains_url checks if a URL is in the exclusions,
+// either directly or because its host is
 func (e *Exclusions) Contains_url(url string) bool {
-	_, exists := e.URLs[normalize_url(url)]
-	return exists
+	u, err := parseNormalizedURL(url)
+	if err != nil {
+		return false
+	}
+	if e.Contains_domain(u.host) || e.Contains_ip(u.host) {
+		return true
+	}
+	for _, pattern := range e.URLs {
+		if pattern.matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains_service checks if a host:port service is in the exclusions,
+// either directly or because its host is
+func (e *Exclusions) Contains_service(service string) bool {
+	host, port, err := splitService(service)
+	if err != nil {
+		return false
+	}
+	if e.Contains_domain(host) || e.Contains_ip(host) {
+		return true
+	}
+	if _, exists := e.Services[net.JoinHostPort(host, port)]; exists {
+		return true
+	}
+
+	// only url exclusions that cover the whole host and port exclude the service
+	for _, pattern := range e.URLs {
+		if pattern.path != "/" || pattern.host != host {
+			continue
+		}
+		if pattern.port == port || (pattern.port == "" && isDefaultPort(pattern.scheme, port)) {
+			return true
+		}
+	}
+	return false
 }
 
 // Contains checks if a string is in any of the exclusion lists
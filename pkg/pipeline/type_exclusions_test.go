@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExclusionsContainsIP(t *testing.T) {
+	exclusions := MakeExclusion()
+	exclusions.Insert(&Surface{
+		IPs: []string{
+			"192.168.1.100",
+			"192.168.2.0/24",
+			"10.0.0.10-10.0.0.20",
+			"2001:db8:1::/48",
+			"2001:db8::1",
+		},
+	})
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"192.168.1.100", true},
+		{"192.168.1.101", false},
+		{"192.168.2.5", true},
+		{"192.168.2.255", true},
+		{"192.168.3.1", false},
+		{"192.168.2.128/25", true},
+		{"192.168.2.0/23", false},
+		{"10.0.0.9", false},
+		{"10.0.0.10", true},
+		{"10.0.0.15", true},
+		{"10.0.0.20", true},
+		{"10.0.0.21", false},
+		{"10.0.0.12-10.0.0.18", true},
+		{"10.0.0.12-10.0.0.21", false},
+		{"2001:db8:1::42", true},
+		{"2001:db8:1:ffff::/64", true},
+		{"2001:db8:2::1", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+		{"not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := exclusions.Contains_ip(tt.ip); got != tt.expected {
+				t.Errorf("Contains_ip(%q) = %v, want %v", tt.ip, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExclusionsTrimIPs(t *testing.T) {
+	tests := []struct {
+		name       string
+		exclusions []string
+		ips        []string
+		expected   []string
+	}{
+		{
+			name:       "no exclusions",
+			exclusions: []string{},
+			ips:        []string{"10.0.0.1", "10.0.0.0/24", "2001:db8::/64"},
+			expected:   []string{"10.0.0.1", "10.0.0.0/24", "2001:db8::/64"},
+		},
+		{
+			name:       "fully excluded",
+			exclusions: []string{"10.0.0.0/16"},
+			ips:        []string{"10.0.0.1", "10.0.5.0/24", "10.0.0.1-10.0.0.20", "10.1.0.1"},
+			expected:   []string{"10.1.0.1"},
+		},
+		{
+			name:       "partially excluded network",
+			exclusions: []string{"192.168.2.0/24"},
+			ips:        []string{"192.168.0.0/22"},
+			expected:   []string{"192.168.0.0/23", "192.168.3.0/24"},
+		},
+		{
+			name:       "single address excluded from a network",
+			exclusions: []string{"10.0.0.0"},
+			ips:        []string{"10.0.0.0/30"},
+			expected:   []string{"10.0.0.1", "10.0.0.2/31"},
+		},
+		{
+			name:       "ranges are converted into networks",
+			exclusions: []string{},
+			ips:        []string{"192.168.0.10-192.168.0.20"},
+			expected:   []string{"192.168.0.10/31", "192.168.0.12/30", "192.168.0.16/30", "192.168.0.20"},
+		},
+		{
+			name:       "partially excluded range",
+			exclusions: []string{"192.168.0.12-192.168.0.19"},
+			ips:        []string{"192.168.0.10-192.168.0.20"},
+			expected:   []string{"192.168.0.10/31", "192.168.0.20"},
+		},
+		{
+			name:       "partially excluded ipv6 network",
+			exclusions: []string{"2001:db8::/33"},
+			ips:        []string{"2001:db8::/32"},
+			expected:   []string{"2001:db8:8000::/33"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exclusions := MakeExclusion()
+			exclusions.Insert(&Surface{IPs: tt.exclusions})
+
+			got := exclusions.Trim_ips(tt.ips)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Trim_ips() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExclusionsContainsDomain(t *testing.T) {
+	exclusions := MakeExclusion()
+	exclusions.Insert(&Surface{
+		Domains: []string{
+			"internal.example.com",
+			"=admin.example.com",
+			"*.cdn.example.com",
+			"=*.static.example.com",
+			"build-*.example.org",
+		},
+	})
+
+	tests := []struct {
+		domain   string
+		expected bool
+	}{
+		// subtree
+		{"internal.example.com", true},
+		{"db.internal.example.com", true},
+		{"a.b.internal.example.com", true},
+		{"DB.Internal.Example.com", true},
+		{"db.internal.example.com.", true},
+		{"notinternal.example.com", false},
+		{"example.com", false},
+		// exact
+		{"admin.example.com", true},
+		{"api.admin.example.com", false},
+		// wildcard
+		{"cdn.example.com", false},
+		{"eu.cdn.example.com", true},
+		{"img.eu.cdn.example.com", true},
+		// exact wildcard
+		{"static.example.com", false},
+		{"eu.static.example.com", true},
+		{"img.eu.static.example.com", false},
+		// partial label wildcard
+		{"build-42.example.org", true},
+		{"a.build-42.example.org", true},
+		{"build.example.org", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := exclusions.Contains_domain(tt.domain); got != tt.expected {
+				t.Errorf("Contains_domain(%q) = %v, want %v", tt.domain, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExcludedSubtreesNeverEnterPipeline(t *testing.T) {
+	exclusions := MakeExclusion()
+	exclusions.Insert(&Surface{
+		Domains: []string{"internal.example.com", "*.cdn.example.com"},
+	})
+
+	pipeline := Surface{}
+	insert_safe(Surface{Domains: []string{"example.com"}}, exclusions, &pipeline, nil, "")
+
+	// domains found later by subfinder or alterx
+	discovered := []string{
+		"www.example.com",
+		"internal.example.com",
+		"db.internal.example.com",
+		"a.db.internal.example.com",
+		"cdn.example.com",
+		"eu.cdn.example.com",
+	}
+	insert_safe_string(discovered, exclusions.Contains_domain, &pipeline.Domains, nil, "")
+
+	expected := []string{"example.com", "www.example.com", "cdn.example.com"}
+	if !reflect.DeepEqual(pipeline.Domains, expected) {
+		t.Errorf("pipeline domains = %v, want %v", pipeline.Domains, expected)
+	}
+}
+
+func TestExclusionsContainsURL(t *testing.T) {
+	exclusions := MakeExclusion()
+	exclusions.Insert(&Surface{
+		Domains: []string{"internal.example.com"},
+		IPs:     []string{"192.0.2.0/24"},
+		URLs: []string{
+			"https://example.com/admin",
+			"example.com/internal/",
+			"https://example.com:8443",
+			"HTTPS://Docs.Example.com/*/Private",
+			"http://example.org/",
+		},
+	})
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		// path prefix
+		{"https://example.com/admin", true},
+		{"https://example.com/admin/", true},
+		{"https://example.com/admin/users", true},
+		{"https://example.com/admin?page=2", true},
+		{"https://example.com/administrator", false},
+		{"https://example.com/Admin", false},
+		{"https://example.com", false},
+		{"http://example.com/admin", false},
+		// normalized scheme, host and default port
+		{"HTTPS://EXAMPLE.COM:443/admin/users", true},
+		{"https://example.com.:443/admin", true},
+		{"https://example.com:444/admin", false},
+		// exclusion without a scheme
+		{"https://example.com/internal", true},
+		{"http://example.com/internal/docs", true},
+		{"http://example.com:80/internal", true},
+		{"example.com/internal/docs", true},
+		// whole host and port
+		{"https://example.com:8443", true},
+		{"https://example.com:8443/anything/at/all", true},
+		{"http://example.com:8443/", false},
+		{"http://example.org/anything", true},
+		{"https://example.org/anything", false},
+		// glob
+		{"https://docs.example.com/v1/Private", true},
+		{"https://docs.example.com/v2/Private/report.pdf", true},
+		{"https://docs.example.com/v2/private", false},
+		{"https://docs.example.com/v1/v2/Private", false},
+		// excluded hosts
+		{"https://db.internal.example.com/login", true},
+		{"http://192.0.2.10:8080/", true},
+		{"http://198.51.100.10/", false},
+		{"not a url", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := exclusions.Contains_url(tt.url); got != tt.expected {
+				t.Errorf("Contains_url(%q) = %v, want %v", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExclusionsContainsService(t *testing.T) {
+	exclusions := MakeExclusion()
+	exclusions.Insert(&Surface{
+		Domains:  []string{"internal.example.com"},
+		IPs:      []string{"192.0.2.0/24"},
+		URLs:     []string{"https://example.com:8443", "example.org", "https://example.net/admin"},
+		Services: []string{"Example.com:22", "[2001:db8::1]:8080"},
+	})
+
+	tests := []struct {
+		service  string
+		expected bool
+	}{
+		// explicit service exclusions
+		{"example.com:22", true},
+		{"EXAMPLE.COM.:22", true},
+		{"example.com:2222", false},
+		{"[2001:db8::1]:8080", true},
+		{"[2001:db8::1]:80", false},
+		// excluded hosts
+		{"db.internal.example.com:5432", true},
+		{"192.0.2.10:8080", true},
+		{"198.51.100.10:8080", false},
+		// url exclusions covering the whole host and port
+		{"example.com:8443", true},
+		{"example.org:80", true},
+		{"example.org:443", true},
+		{"example.org:8080", false},
+		{"example.net:443", false},
+		{"not a service", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			if got := exclusions.Contains_service(tt.service); got != tt.expected {
+				t.Errorf("Contains_service(%q) = %v, want %v", tt.service, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// HTTPInfo holds what an http probe learned about a single URL
+type HTTPInfo struct {
+	StatusCode    int    `yaml:"status_code"`
+	Title         string `yaml:"title,omitempty"`
+	ContentLength int    `yaml:"content_length"`
+	// BodyHash is the sha256 of the response body
+	BodyHash string `yaml:"body_hash,omitempty"`
+	// Redirects are the locations the URL redirected to, in order
+	Redirects []string `yaml:"redirects,omitempty"`
+	// Server is the content of the Server header
+	Server       string   `yaml:"server,omitempty"`
+	Technologies []string `yaml:"technologies,omitempty"`
+	TLS          *TLSInfo `yaml:"tls,omitempty"`
+	CDN          bool     `yaml:"cdn,omitempty"`
+	CDNName      string   `yaml:"cdn_name,omitempty"`
+	// ResponseTime is rounded to the millisecond
+	ResponseTime time.Duration `yaml:"response_time"`
+	// Body is the beginning of the response body, searched for takeover fingerprints.
+	// It is only used during a run, and never saved
+	Body string `yaml:"-" json:"-"`
+}
+
+// TLSInfo holds the leaf certificate served on a URL
+type TLSInfo struct {
+	Subject  string    `yaml:"subject"`
+	SANs     []string  `yaml:"sans,omitempty"`
+	Issuer   string    `yaml:"issuer,omitempty"`
+	NotAfter time.Time `yaml:"not_after"`
+}
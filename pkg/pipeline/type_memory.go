@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+)
+
+// Memory is the pipeline memory: the values written by the operators, in named slots.
+// Values in the memory are never modified: operators that transform
+// a value write a modified copy.
+type Memory struct {
+	mutex sync.RWMutex
+	slots map[string]memorySlot
+}
+
+type memorySlot struct {
+	dataType DataType
+	value    any
+}
+
+func NewMemory() *Memory {
+	return &Memory{slots: make(map[string]memorySlot)}
+}
+
+// Set writes value in the slot name, after checking that it is of type dataType
+func (m *Memory) Set(name string, dataType DataType, value any) error {
+	if !dataType.Check(value) {
+		return fmt.Errorf("slot %s: value of type %T is not of type %s", name, value, dataType)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.slots[name] = memorySlot{dataType, value}
+	return nil
+}
+
+// Get returns the value in the slot name, and its type
+func (m *Memory) Get(name string) (any, DataType, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	slot, exists := m.slots[name]
+	return slot.value, slot.dataType, exists
+}
+
+// Check checks if value is of the Go type that represents the DataType
+func (t DataType) Check(value any) bool {
+	switch t {
+	case TypeSurface:
+		_, ok := value.(Surface)
+		return ok
+	case TypeDomains, TypeIPs, TypeURLs, TypeServices:
+		_, ok := value.([]string)
+		return ok
+	case TypePorts:
+		_, ok := value.([]int)
+		return ok
+	case TypeWildcards:
+		_, ok := value.([]Wildcard)
+		return ok
+	case TypeHTTP:
+		_, ok := value.(map[string]HTTPInfo)
+		return ok
+	case TypeDNS:
+		_, ok := value.(map[string]DNSRecords)
+		return ok
+	case TypeIssues:
+		_, ok := value.([]issues.Issue)
+		return ok
+	}
+	return false
+}
+
+// Node is an instance of an operator in a pipeline
+type Node struct {
+	// ID is unique in the pipeline. The outputs of the node
+	// are written in the memory slots id.output
+	ID       string `yaml:"id" json:"id"`
+	Operator string `yaml:"operator" json:"operator"`
+	Params   Params `yaml:"params,omitempty" json:"params,omitempty"`
+	// Inputs are the memory slots the inputs of the node are read from, by socket name
+	Inputs map[string]string `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+}
+
+// Slot returns the memory slot an output of the node is written in
+func (n *Node) Slot(output string) string {
+	return n.ID + "." + output
+}
+
+// Validate checks that the operator of the node exists, that its parameters and
+// the configuration it reads are valid, and that all its required inputs are connected
+func (n *Node) Validate(config *Config) error {
+	op, exists := LookupOperator(n.Operator)
+	if !exists {
+		return fmt.Errorf("node %s: unknown operator '%s'", n.ID, n.Operator)
+	}
+	if err := op.ValidateConfig(n.Params, config); err != nil {
+		return fmt.Errorf("node %s: %w", n.ID, err)
+	}
+
+	for name := range n.Inputs {
+		if _, found := findSocket(op.Inputs(), name); !found {
+			return fmt.Errorf("node %s: operator %s has no input '%s'", n.ID, n.Operator, name)
+		}
+	}
+	for _, socket := range op.Inputs() {
+		if _, connected := n.Inputs[socket.Name]; !connected && !socket.Optional {
+			return fmt.Errorf("node %s: input '%s' is not connected", n.ID, socket.Name)
+		}
+	}
+	return nil
+}
+
+// Execute runs the operator of a node: it reads the inputs from the memory,
+// and writes the outputs back into it.
+func (m *Memory) Execute(ctx context.Context, env *Env, n *Node) (Values, Values, error) {
+	op, exists := LookupOperator(n.Operator)
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown operator '%s'", n.Operator)
+	}
+
+	in := Values{}
+	for _, socket := range op.Inputs() {
+		slot, connected := n.Inputs[socket.Name]
+		if !connected {
+			continue
+		}
+		value, dataType, exists := m.Get(slot)
+		if !exists {
+			return nil, nil, fmt.Errorf("input '%s': slot %s is empty", socket.Name, slot)
+		}
+		if dataType != socket.Type {
+			return nil, nil, fmt.Errorf("input '%s' is of type %s, but slot %s is of type %s", socket.Name, socket.Type, slot, dataType)
+		}
+		in[socket.Name] = value
+	}
+
+	out, err := op.Execute(ctx, env, n.Params, in)
+	if err != nil {
+		return in, nil, err
+	}
+
+	for _, socket := range op.Outputs() {
+		value, exists := out[socket.Name]
+		if !exists {
+			return in, nil, fmt.Errorf("operator %s did not write its output '%s'", n.Operator, socket.Name)
+		}
+		if err := m.Set(n.Slot(socket.Name), socket.Type, value); err != nil {
+			return in, nil, err
+		}
+	}
+	return in, out, nil
+}
+
+func findSocket(sockets []Socket, name string) (Socket, bool) {
+	for _, socket := range sockets {
+		if socket.Name == name {
+			return socket, true
+		}
+	}
+	return Socket{}, false
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"slices"
+	"time"
+)
+
+// Names of the sources that can discover a surface element
+const (
+	SourceScope     = "scope"
+	SourceURL       = "url"
+	SourceSubfinder = "subfinder"
+	SourceAlterx    = "alterx"
+	SourceDNS       = "dns"
+	SourceHttpx     = "httpx"
+)
+
+// Asset holds the provenance of a single surface element:
+// which sources discovered it, and when.
+type Asset struct {
+	Sources   []string  `yaml:"sources"`
+	FirstSeen time.Time `yaml:"first_seen"`
+	LastSeen  time.Time `yaml:"last_seen"`
+}
+
+// Provenance tracks the Asset of every surface element seen in a run,
+// on top of the assets known from previous runs
+type Provenance struct {
+	assets map[string]Asset
+	now    time.Time
+}
+
+// NewProvenance initializes a Provenance from the assets known from previous runs.
+// now is the timestamp that will be recorded for everything seen in this run
+func NewProvenance(known map[string]Asset, now time.Time) *Provenance {
+	p := &Provenance{
+		assets: make(map[string]Asset, len(known)),
+		now:    now.UTC().Truncate(time.Second),
+	}
+	for value, asset := range known {
+		asset.Sources = slices.Clone(asset.Sources)
+		p.assets[value] = asset
+	}
+	return p
+}
+
+// Record marks value as seen in this run, by the given source
+func (p *Provenance) Record(value string, source string) {
+	asset, exists := p.assets[value]
+	if !exists {
+		asset.FirstSeen = p.now
+	}
+	asset.LastSeen = p.now
+	if !slices.Contains(asset.Sources, source) {
+		asset.Sources = append(asset.Sources, source)
+		slices.Sort(asset.Sources)
+	}
+	p.assets[value] = asset
+}
+
+// Get returns the Asset of value, if it was ever seen
+func (p *Provenance) Get(value string) (Asset, bool) {
+	asset, exists := p.assets[value]
+	return asset, exists
+}
+
+// Seen returns the values that were recorded during this run
+func (p *Provenance) Seen(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if asset, exists := p.assets[value]; exists && asset.LastSeen.Equal(p.now) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// Assets returns the Asset of every element of the given surface
+func (p *Provenance) Assets(s Surface) map[string]Asset {
+	result := make(map[string]Asset)
+	for _, list := range [][]string{s.Domains, s.IPs, s.URLs, s.Services} {
+		for _, value := range list {
+			if asset, exists := p.assets[value]; exists {
+				result[value] = asset
+			}
+		}
+	}
+	return result
+}
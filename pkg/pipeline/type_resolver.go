@@ -0,0 +1,338 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+)
+
+// defaultResolvers are the dns servers queried when none is configured.
+// They are public resolvers known not to hijack name errors
+var defaultResolvers = []string{
+	"1.1.1.1:53",         // Cloudflare
+	"1.0.0.1:53",         // Cloudflare
+	"8.8.8.8:53",         // Google
+	"8.8.4.4:53",         // Google
+	"9.9.9.9:53",         // Quad9
+	"149.112.112.112:53", // Quad9
+}
+
+// minCacheTTL is the shortest time a resolution is cached for,
+// so that it is still in the cache when the next stage of the run reads it
+const minCacheTTL = time.Minute
+
+// Resolver resolves domains into IPs. Queries are spread over a pool
+// of concurrent workers, and across all the servers, within a shared rate limit.
+type Resolver struct {
+	servers   []string
+	udpClient *dns.Client
+	tcpClient *dns.Client
+	config    DNSConfig
+	limiter   *rate.Limiter
+	next      atomic.Uint32
+	// trusted re-checks the positive answers, when set
+	trusted *Resolver
+}
+
+// NewResolver initializes a Resolver that queries the configured servers,
+// or the default ones when none is configured.
+// Invalid servers are rejected when parsing the configuration files.
+func NewResolver(config DNSConfig) *Resolver {
+	servers := defaultResolvers
+	if len(config.Resolvers) > 0 {
+		servers = resolverAddresses(config.Resolvers)
+	}
+	r := newResolver(servers, config)
+	if len(config.TrustedResolvers) > 0 {
+		r.trusted = newResolver(resolverAddresses(config.TrustedResolvers), config)
+	}
+	return r
+}
+
+func newResolver(servers []string, config DNSConfig) *Resolver {
+	timeout := time.Duration(config.Timeout) * time.Second
+	return &Resolver{
+		servers:   servers,
+		udpClient: &dns.Client{Net: "udp", Timeout: timeout},
+		tcpClient: &dns.Client{Net: "tcp", Timeout: timeout},
+		config:    config,
+		limiter:   rate.NewLimiter(rate.Limit(config.RateLimit), 1),
+	}
+}
+
+// resolverAddress normalizes a dns server in the format ip or ip:port into ip:port
+func resolverAddress(server string) (string, error) {
+	server = strings.TrimSpace(server)
+	if addr, err := netip.ParseAddr(server); err == nil {
+		return net.JoinHostPort(addr.String(), "53"), nil
+	}
+	addrPort, err := netip.ParseAddrPort(server)
+	if err != nil {
+		return "", fmt.Errorf("'%s' must be an ip, or an ip:port", server)
+	}
+	if addrPort.Port() == 0 {
+		return "", fmt.Errorf("'%s' has an invalid port", server)
+	}
+	return addrPort.String(), nil
+}
+
+func resolverAddresses(servers []string) []string {
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if address, err := resolverAddress(server); err == nil {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// Lookup returns the A and AAAA records of a domain.
+// A domain that does not exist, or that has no address, has no IPs and no error.
+// The A and AAAA queries are independent: many authoritative servers mishandle
+// AAAA queries, so the A records are returned when only the AAAA query fails.
+// An error is returned when no server answered a query, after all the retries,
+// and the other query found no address but did not tell that the domain does
+// not exist, or when the context is done.
+// When there are trusted servers, a domain that resolved is resolved again
+// with them, and their answer is returned.
+func (r *Resolver) Lookup(ctx context.Context, domain string) ([]string, error) {
+	ips, _, err := r.lookupTTL(ctx, domain)
+	return ips, err
+}
+
+// lookupTTL is Lookup, that also returns for how long the IPs can be cached:
+// the lowest TTL of the address records
+func (r *Resolver) lookupTTL(ctx context.Context, domain string) ([]string, time.Duration, error) {
+	ips, ttl, err := r.lookup(ctx, domain)
+	if err != nil || len(ips) == 0 || r.trusted == nil {
+		return ips, ttl, err
+	}
+	return r.trusted.lookup(ctx, domain)
+}
+
+func (r *Resolver) lookup(ctx context.Context, domain string) ([]string, time.Duration, error) {
+	if addr, err := netip.ParseAddr(domain); err == nil {
+		return []string{addr.String()}, 24 * time.Hour, nil
+	}
+
+	ips := []string{}
+	var ttl uint32
+	var errs []error
+	nameError := false
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := r.query(ctx, domain, qtype)
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		nameError = nameError || resp.Rcode == dns.RcodeNameError
+		for _, rr := range resp.Answer {
+			switch record := rr.(type) {
+			case *dns.A:
+				ips = append(ips, record.A.String())
+			case *dns.AAAA:
+				ips = append(ips, record.AAAA.String())
+			default:
+				continue
+			}
+			if len(ips) == 1 || rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+	}
+	// a failed query does not tell that the domain has no address,
+	// unless the other one found that the domain does not exist
+	if len(errs) > 0 && len(ips) == 0 && !nameError {
+		return nil, 0, errs[0]
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// query sends a question to the servers, in turn, until one of them gives a
+// definitive answer: a success, or a name error
+func (r *Resolver) query(ctx context.Context, domain string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(domain), qtype)
+
+	var lastErr error
+	for attempt := 0; attempt <= r.config.Retries; attempt++ {
+		if err := r.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		server := r.servers[int(r.next.Add(1))%len(r.servers)]
+
+		resp, _, err := r.udpClient.ExchangeContext(ctx, msg, server)
+		if err == nil && resp.Truncated {
+			resp, _, err = r.tcpClient.ExchangeContext(ctx, msg, server)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		switch resp.Rcode {
+		case dns.RcodeSuccess, dns.RcodeNameError:
+			return resp, nil
+		default:
+			lastErr = fmt.Errorf("server %s answered %s", server, dns.RcodeToString[resp.Rcode])
+		}
+	}
+	return nil, fmt.Errorf("failed to resolve %s: %w", domain, lastErr)
+}
+
+// ResolveAll resolves all the domains that are not in the cache, and caches their IPs
+// for the TTL of their records. Domains that do not exist, or have no address, are
+// cached without IPs for the negative TTL.
+// Domains that could not be resolved, because no server gave a definitive answer,
+// are cached without IPs for the current run only: a flaky resolver must not
+// hide live domains from the next runs.
+// It stops early, returning the context error, when the context is done.
+func (r *Resolver) ResolveAll(ctx context.Context, domains []string, cache *DNSCache) error {
+	var pending []string
+	for _, domain := range domains {
+		if _, found := cache.Get(domain); !found {
+			pending = append(pending, domain)
+		}
+	}
+
+	negativeTTL := time.Duration(r.config.NegativeTTL) * time.Second
+	return r.forEach(ctx, pending, func(domain string) {
+		ips, ttl, err := r.lookupTTL(ctx, domain)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			cache.Set(domain, []string{})
+			return
+		}
+		if len(ips) == 0 {
+			cache.SetWithTTL(domain, []string{}, max(negativeTTL, minCacheTTL))
+			return
+		}
+		cache.SetWithTTL(domain, ips, max(ttl, minCacheTTL))
+	})
+}
+
+// forEach calls fn for every domain, from a pool of concurrent workers.
+// It stops early, returning the context error, when the context is done.
+func (r *Resolver) forEach(ctx context.Context, domains []string, fn func(domain string)) error {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(r.config.Threads, len(domains)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range jobs {
+				fn(domain)
+			}
+		}()
+	}
+
+feed:
+	for _, domain := range domains {
+		select {
+		case jobs <- domain:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// Records returns the CNAME chain, and the A, AAAA, NS, MX and TXT records of a domain.
+// A domain that does not exist has no records, and NXDOMAIN set.
+func (r *Resolver) Records(ctx context.Context, domain string) (DNSRecords, error) {
+	records := DNSRecords{}
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeNS, dns.TypeMX, dns.TypeTXT} {
+		resp, err := r.query(ctx, domain, qtype)
+		if err != nil {
+			return DNSRecords{}, err
+		}
+
+		if qtype == dns.TypeA {
+			records.CNAME = cnameChain(dns.Fqdn(domain), resp.Answer)
+			records.NXDOMAIN = resp.Rcode == dns.RcodeNameError
+		}
+		for _, rr := range resp.Answer {
+			switch record := rr.(type) {
+			case *dns.A:
+				records.A = append(records.A, record.A.String())
+			case *dns.AAAA:
+				records.AAAA = append(records.AAAA, record.AAAA.String())
+			case *dns.NS:
+				records.NS = append(records.NS, normalize_domain(record.Ns))
+			case *dns.MX:
+				records.MX = append(records.MX, fmt.Sprintf("%d %s", record.Preference, normalize_domain(record.Mx)))
+			case *dns.TXT:
+				records.TXT = append(records.TXT, strings.Join(record.Txt, ""))
+			}
+		}
+	}
+
+	for _, list := range []*[]string{&records.A, &records.AAAA, &records.NS, &records.MX, &records.TXT} {
+		slices.Sort(*list)
+		*list = slices.Compact(*list)
+	}
+	return records, nil
+}
+
+// cnameChain follows the CNAME records of an answer, starting from name
+func cnameChain(name string, answer []dns.RR) []string {
+	var chain []string
+	seen := map[string]bool{}
+	for !seen[name] {
+		seen[name] = true
+		next := ""
+		for _, rr := range answer {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		chain = append(chain, normalize_domain(next))
+		name = next
+	}
+	return chain
+}
+
+// ResolveRecords collects the dns records of all the domains.
+// Domains that can't be resolved are left out.
+// It stops early, returning the context error, when the context is done.
+func (r *Resolver) ResolveRecords(ctx context.Context, domains []string) (map[string]DNSRecords, error) {
+	var mu sync.Mutex
+	result := make(map[string]DNSRecords, len(domains))
+	err := r.forEach(ctx, domains, func(domain string) {
+		records, err := r.Records(ctx, domain)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		result[domain] = records
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
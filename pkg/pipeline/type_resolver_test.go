@@ -0,0 +1,513 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startTestDNSServer starts a dns server on a random local port, and returns its address
+func startTestDNSServer(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// zoneHandler answers with the given records, and with a name error for everything else
+func zoneHandler(records map[string][]string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		ips, exists := records[question.Name]
+		if !exists {
+			resp.Rcode = dns.RcodeNameError
+		}
+		for _, ip := range ips {
+			isV4 := net.ParseIP(ip).To4() != nil
+			switch {
+			case question.Qtype == dns.TypeA && isV4:
+				rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN A %s", question.Name, ip))
+				resp.Answer = append(resp.Answer, rr)
+			case question.Qtype == dns.TypeAAAA && !isV4:
+				rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN AAAA %s", question.Name, ip))
+				resp.Answer = append(resp.Answer, rr)
+			}
+		}
+		w.WriteMsg(resp)
+	}
+}
+
+func testDNSConfig() DNSConfig {
+	return DNSConfig{Threads: 10, RateLimit: 10000, Retries: 0, Timeout: 1}
+}
+
+func TestResolverLookup(t *testing.T) {
+	server := startTestDNSServer(t, zoneHandler(map[string][]string{
+		"example.com.":       {"192.0.2.1", "192.0.2.2"},
+		"v6.example.com.":    {"2001:db8::1"},
+		"empty.example.com.": {},
+	}))
+	resolver := newResolver([]string{server}, testDNSConfig())
+
+	tests := []struct {
+		domain   string
+		expected []string
+	}{
+		{"example.com", []string{"192.0.2.1", "192.0.2.2"}},
+		{"v6.example.com", []string{"2001:db8::1"}},
+		{"empty.example.com", []string{}},
+		{"nxdomain.example.com", []string{}},
+		{"198.51.100.1", []string{"198.51.100.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			ips, err := resolver.Lookup(context.Background(), tt.domain)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(ips, tt.expected) {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.domain, ips, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolverLookupPartialFailure(t *testing.T) {
+	// the server fails the AAAA queries of every name, and all the queries of broken.example.com
+	zone := zoneHandler(map[string][]string{
+		"example.com.":        {"192.0.2.1"},
+		"broken.example.com.": {"192.0.2.2"},
+	})
+	server := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Qtype == dns.TypeAAAA || req.Question[0].Name == "broken.example.com." {
+			resp := new(dns.Msg)
+			resp.SetRcode(req, dns.RcodeServerFailure)
+			w.WriteMsg(resp)
+			return
+		}
+		zone(w, req)
+	})
+	resolver := newResolver([]string{server}, testDNSConfig())
+
+	ips, err := resolver.Lookup(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Expected the A records despite the failed AAAA query, got: %v", err)
+	}
+	if !reflect.DeepEqual(ips, []string{"192.0.2.1"}) {
+		t.Errorf("Unexpected ips: %v", ips)
+	}
+
+	// a name error is definitive, even when the other query failed
+	ips, err = resolver.Lookup(context.Background(), "nxdomain.example.com")
+	if err != nil || len(ips) != 0 {
+		t.Errorf("Expected no ips and no error for a name error, got %v, %v", ips, err)
+	}
+	if _, err := resolver.Lookup(context.Background(), "broken.example.com"); err == nil {
+		t.Errorf("Expected an error when both queries failed")
+	}
+}
+
+func TestResolverResolveAll(t *testing.T) {
+	records := map[string][]string{}
+	var domains []string
+	for i := 0; i < 200; i++ {
+		domain := fmt.Sprintf("host%d.example.com", i)
+		domains = append(domains, domain)
+		if i%2 == 0 {
+			records[domain+"."] = []string{fmt.Sprintf("192.0.2.%d", i)}
+		}
+	}
+	server := startTestDNSServer(t, zoneHandler(records))
+	resolver := newResolver([]string{server}, testDNSConfig())
+
+	cache := NewDNSCache()
+	cache.Set("host0.example.com", []string{"203.0.113.1"})
+	active, err := FilterActive(context.Background(), domains, cache, resolver)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(active) != 100 {
+		t.Errorf("Expected 100 active domains, got %d", len(active))
+	}
+	// the order of the input is kept
+	if active[0] != "host0.example.com" || active[1] != "host2.example.com" {
+		t.Errorf("Unexpected order: %v", active[:2])
+	}
+	// cached domains are not resolved again
+	if ips, _ := cache.Get("host0.example.com"); !reflect.DeepEqual(ips, []string{"203.0.113.1"}) {
+		t.Errorf("Cached value was overwritten: %v", ips)
+	}
+	if ips, found := cache.Get("host1.example.com"); !found || len(ips) != 0 {
+		t.Errorf("Expected an empty cache entry for a missing domain, got %v, %v", ips, found)
+	}
+}
+
+func TestResolverRetries(t *testing.T) {
+	// the server fails the first two queries for every name and type
+	var mu sync.Mutex
+	failures := map[string]int{}
+	zone := zoneHandler(map[string][]string{"example.com.": {"192.0.2.1"}})
+	server := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		key := req.Question[0].String()
+		mu.Lock()
+		failures[key]++
+		fail := failures[key] <= 2
+		mu.Unlock()
+		if fail {
+			resp := new(dns.Msg)
+			resp.SetRcode(req, dns.RcodeServerFailure)
+			w.WriteMsg(resp)
+			return
+		}
+		zone(w, req)
+	})
+
+	config := testDNSConfig()
+	config.Retries = 1
+	if _, err := newResolver([]string{server}, config).Lookup(context.Background(), "example.com"); err == nil {
+		t.Errorf("Expected an error after too few retries")
+	}
+
+	config.Retries = 2
+	ips, err := newResolver([]string{server}, config).Lookup(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(ips, []string{"192.0.2.1"}) {
+		t.Errorf("Unexpected ips: %v", ips)
+	}
+}
+
+func TestResolverRateLimit(t *testing.T) {
+	server := startTestDNSServer(t, zoneHandler(map[string][]string{}))
+	config := testDNSConfig()
+	config.RateLimit = 50
+
+	var domains []string
+	for i := 0; i < 10; i++ {
+		domains = append(domains, fmt.Sprintf("host%d.example.com", i))
+	}
+
+	// 10 domains are 20 queries, one for A and one for AAAA records
+	start := time.Now()
+	err := newResolver([]string{server}, config).ResolveAll(context.Background(), domains, NewDNSCache())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("20 queries at 50 per second took only %v", elapsed)
+	}
+}
+
+func TestResolverCancel(t *testing.T) {
+	// the server never answers
+	server := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {})
+	config := testDNSConfig()
+	config.Timeout = 30
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	cache := NewDNSCache()
+	_, err := FilterActive(ctx, []string{"a.example.com", "b.example.com"}, cache, newResolver([]string{server}, config))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Cancellation took %v", elapsed)
+	}
+	if _, found := cache.Get("a.example.com"); found {
+		t.Errorf("Interrupted lookups must not be cached")
+	}
+}
+
+func TestResolverCustomResolvers(t *testing.T) {
+	// a split-horizon name, only known to the internal server
+	internal := startTestDNSServer(t, zoneHandler(map[string][]string{
+		"intranet.example.com.": {"10.0.0.1"},
+	}))
+
+	config := testDNSConfig()
+	config.Resolvers = []string{internal}
+	ips, err := NewResolver(config).Lookup(context.Background(), "intranet.example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(ips, []string{"10.0.0.1"}) {
+		t.Errorf("Unexpected ips: %v", ips)
+	}
+}
+
+func TestResolverTrustedResolvers(t *testing.T) {
+	// the untrusted server hijacks name errors, answering everything with its own ip
+	poisoned := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if req.Question[0].Qtype == dns.TypeA {
+			rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 198.51.100.66")
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp)
+	})
+	trusted := startTestDNSServer(t, zoneHandler(map[string][]string{
+		"www.example.com.": {"192.0.2.1"},
+	}))
+
+	domains := []string{"www.example.com", "nxdomain.example.com"}
+
+	config := testDNSConfig()
+	config.Resolvers = []string{poisoned}
+	active, err := FilterActive(context.Background(), domains, NewDNSCache(), NewResolver(config))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(active, domains) {
+		t.Errorf("Expected the poisoned server to resolve everything, got %v", active)
+	}
+
+	config.TrustedResolvers = []string{trusted}
+	cache := NewDNSCache()
+	active, err = FilterActive(context.Background(), domains, cache, NewResolver(config))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(active, []string{"www.example.com"}) {
+		t.Errorf("Expected only the domains confirmed by the trusted server, got %v", active)
+	}
+	if ips, _ := cache.Get("www.example.com"); !reflect.DeepEqual(ips, []string{"192.0.2.1"}) {
+		t.Errorf("Expected the ips of the trusted server, got %v", ips)
+	}
+}
+
+func TestResolverAddress(t *testing.T) {
+	tests := []struct {
+		server   string
+		expected string
+		wantErr  bool
+	}{
+		{"1.1.1.1", "1.1.1.1:53", false},
+		{"10.0.0.53:5353", "10.0.0.53:5353", false},
+		{"2001:db8::53", "[2001:db8::53]:53", false},
+		{"[2001:db8::53]:5353", "[2001:db8::53]:5353", false},
+		{"dns.example.com", "", true},
+		{"1.1.1.1:0", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			got, err := resolverAddress(tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolverAddress(%q) error = %v, wantErr %v", tt.server, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("resolverAddress(%q) = %q, want %q", tt.server, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolverCacheTTL(t *testing.T) {
+	server := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		switch {
+		case question.Name == "example.com." && question.Qtype == dns.TypeA:
+			rr1, _ := dns.NewRR("example.com. 600 IN A 192.0.2.1")
+			rr2, _ := dns.NewRR("example.com. 300 IN A 192.0.2.2")
+			resp.Answer = append(resp.Answer, rr1, rr2)
+		case question.Name == "short.example.com." && question.Qtype == dns.TypeA:
+			rr, _ := dns.NewRR("short.example.com. 5 IN A 192.0.2.3")
+			resp.Answer = append(resp.Answer, rr)
+		case question.Name != "example.com." && question.Name != "short.example.com.":
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache := NewDNSCache()
+	cache.now = func() time.Time { return now }
+
+	config := testDNSConfig()
+	config.NegativeTTL = 1800
+	domains := []string{"example.com", "short.example.com", "nxdomain.example.com"}
+	if err := newResolver([]string{server}, config).ResolveAll(context.Background(), domains, cache); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]DNSCacheEntry{
+		// the lowest ttl of the records
+		"example.com": {IPs: []string{"192.0.2.1", "192.0.2.2"}, Expires: now.Add(300 * time.Second)},
+		// short ttls last at least until the next stage of the run
+		"short.example.com":    {IPs: []string{"192.0.2.3"}, Expires: now.Add(minCacheTTL)},
+		"nxdomain.example.com": {IPs: []string{}, Expires: now.Add(1800 * time.Second)},
+	}
+	if entries := cache.Entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Entries mismatch.\nExpected: %v\nGot: %v", expected, entries)
+	}
+}
+
+func TestResolverResolveAllFailures(t *testing.T) {
+	// the server fails every query for broken.example.com
+	zone := zoneHandler(map[string][]string{"example.com.": {"192.0.2.1"}})
+	server := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name == "broken.example.com." {
+			resp := new(dns.Msg)
+			resp.SetRcode(req, dns.RcodeServerFailure)
+			w.WriteMsg(resp)
+			return
+		}
+		zone(w, req)
+	})
+
+	config := testDNSConfig()
+	config.NegativeTTL = 3600
+	cache := NewDNSCache()
+	domains := []string{"example.com", "broken.example.com", "nxdomain.example.com"}
+	if err := newResolver([]string{server}, config).ResolveAll(context.Background(), domains, cache); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// the failure is remembered for the current run
+	if ips, found := cache.Get("broken.example.com"); !found || len(ips) != 0 {
+		t.Errorf("Expected an empty cache entry for the failed domain, got %v, %v", ips, found)
+	}
+	// but not persisted for the next ones, unlike the name error
+	entries := cache.Entries()
+	if _, persisted := entries["broken.example.com"]; persisted {
+		t.Errorf("A SERVFAIL answer must not be persisted: %v", entries)
+	}
+	if _, persisted := entries["nxdomain.example.com"]; !persisted {
+		t.Errorf("Expected the name error to be persisted: %v", entries)
+	}
+}
+
+func TestResolverRecords(t *testing.T) {
+	zone := map[uint16][]string{
+		dns.TypeA: {
+			"www.example.com. 60 IN CNAME edge.example.net.",
+			"edge.example.net. 60 IN CNAME edge.cdn.example.org.",
+			"edge.cdn.example.org. 60 IN A 192.0.2.2",
+			"edge.cdn.example.org. 60 IN A 192.0.2.1",
+			"example.com. 60 IN A 192.0.2.10",
+			"dangling.example.com. 60 IN CNAME gone.s3.example.org.",
+		},
+		dns.TypeAAAA: {
+			"example.com. 60 IN AAAA 2001:db8::10",
+		},
+		dns.TypeNS: {
+			"example.com. 60 IN NS NS2.example.net.",
+			"example.com. 60 IN NS ns1.example.net.",
+		},
+		dns.TypeMX: {
+			"example.com. 60 IN MX 20 mx2.example.com.",
+			"example.com. 60 IN MX 10 mx1.example.com.",
+		},
+		dns.TypeTXT: {
+			`example.com. 60 IN TXT "v=spf1 include:_spf.example.net " "-all"`,
+		},
+	}
+	server := startTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		// recursive resolvers answer with the whole chain, so the handler
+		// follows the aliases the same way
+		for name := question.Name; name != ""; {
+			next := ""
+			for _, line := range zone[dns.TypeA] {
+				rr, _ := dns.NewRR(line)
+				if cname, ok := rr.(*dns.CNAME); ok && cname.Hdr.Name == name {
+					resp.Answer = append(resp.Answer, rr)
+					next = cname.Target
+				}
+			}
+			for _, line := range zone[question.Qtype] {
+				rr, _ := dns.NewRR(line)
+				if rr.Header().Name == name && rr.Header().Rrtype == question.Qtype {
+					resp.Answer = append(resp.Answer, rr)
+				}
+			}
+			name = next
+		}
+		if name := question.Name; name == "nxdomain.example.com." || name == "dangling.example.com." {
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})
+	resolver := newResolver([]string{server}, testDNSConfig())
+
+	tests := []struct {
+		domain   string
+		expected DNSRecords
+	}{
+		{
+			domain: "example.com",
+			expected: DNSRecords{
+				A:    []string{"192.0.2.10"},
+				AAAA: []string{"2001:db8::10"},
+				NS:   []string{"ns1.example.net", "ns2.example.net"},
+				MX:   []string{"10 mx1.example.com", "20 mx2.example.com"},
+				TXT:  []string{"v=spf1 include:_spf.example.net -all"},
+			},
+		},
+		{
+			domain: "www.example.com",
+			expected: DNSRecords{
+				CNAME: []string{"edge.example.net", "edge.cdn.example.org"},
+				A:     []string{"192.0.2.1", "192.0.2.2"},
+			},
+		},
+		{
+			domain: "dangling.example.com",
+			expected: DNSRecords{
+				CNAME:    []string{"gone.s3.example.org"},
+				NXDOMAIN: true,
+			},
+		},
+		{
+			domain:   "nxdomain.example.com",
+			expected: DNSRecords{NXDOMAIN: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			records, err := resolver.Records(context.Background(), tt.domain)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.expected) {
+				t.Errorf("Records(%q) = %+v, want %+v", tt.domain, records, tt.expected)
+			}
+		})
+	}
+
+	all, err := resolver.ResolveRecords(context.Background(), []string{"example.com", "www.example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(all) != 2 || all["www.example.com"].CanonicalName() != "edge.cdn.example.org" {
+		t.Errorf("Unexpected records: %+v", all)
+	}
+}
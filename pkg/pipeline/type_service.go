@@ -0,0 +1,38 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// splitService splits a host:port service into its normalized host and port.
+// IP hosts are returned in their canonical form, and IPv6 hosts without brackets.
+func splitService(service string) (host string, port string, err error) {
+	host, port, err = net.SplitHostPort(strings.TrimSpace(service))
+	if err != nil {
+		return "", "", err
+	}
+	if host == "" || port == "" {
+		return "", "", fmt.Errorf("service '%s' must be in the format host:port", service)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String(), port, nil
+	}
+	return normalize_domain(host), port, nil
+}
+
+// isDefaultPort checks if port is the default port of the scheme.
+// An empty scheme stands for both http and https.
+func isDefaultPort(scheme string, port string) bool {
+	switch scheme {
+	case "http":
+		return port == "80"
+	case "https":
+		return port == "443"
+	case "":
+		return port == "80" || port == "443"
+	}
+	return false
+}
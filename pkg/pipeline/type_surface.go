@@ -4,4 +4,15 @@ type Surface struct {
 	Domains []string `yaml:"domains"`
 	IPs     []string `yaml:"ips"`
 	URLs    []string `yaml:"urls"`
+	// Services are network services, in the format host:port
+	Services []string `yaml:"services"`
+	// Ports are the ports to probe on every domain and IP, besides the default http ones.
+	// They are only meaningful in the scope: a port of a single host is a service
+	Ports []int `yaml:"ports,omitempty"`
+	// AssetPorts are the ports to probe on a single domain or IP of the scope, besides Ports.
+	// They are only meaningful in the scope, where they are expanded into services
+	AssetPorts map[string][]int `yaml:"asset_ports,omitempty"`
+	// Wildcards are the domains known to be wildcards, like *.preview.example.com.
+	// They are only meaningful in the scope
+	Wildcards []string `yaml:"wildcards,omitempty"`
 }
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// urlPattern is a normalized URL exclusion.
+// It matches all the URLs on the same scheme, host and port,
+// with a path equal to or under its path.
+type urlPattern struct {
+	// scheme is empty when the exclusion has no scheme, and matches both http and https
+	scheme string
+	host   string
+	// port is empty when it is the default port of the scheme
+	port string
+	// path is never empty, and has no trailing slash unless it is the root
+	path string
+	// glob is set when the path contains wildcards
+	glob bool
+}
+
+// normalizedURL is a URL split into the components used for matching
+type normalizedURL struct {
+	scheme string
+	host   string
+	port   string
+	path   string
+}
+
+// parseNormalizedURL splits a URL with an optional scheme into its components.
+// Scheme and host are lowercased, default ports and trailing slashes are removed.
+// The path is kept as it is: paths are case-sensitive.
+// Query and fragment are ignored.
+func parseNormalizedURL(rawURL string) (normalizedURL, error) {
+	rawURL = strings.TrimSpace(rawURL)
+
+	scheme := ""
+	parsable := rawURL
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		scheme = strings.ToLower(rawURL[:i])
+	} else {
+		parsable = "https://" + rawURL
+	}
+
+	parsed, err := url.Parse(parsable)
+	if err != nil {
+		return normalizedURL{}, err
+	}
+	if parsed.Hostname() == "" {
+		return normalizedURL{}, fmt.Errorf("url '%s' has no host", rawURL)
+	}
+
+	port := parsed.Port()
+	switch {
+	case scheme == "http" && port == "80",
+		scheme == "https" && port == "443",
+		scheme == "" && (port == "80" || port == "443"):
+		port = ""
+	}
+
+	urlPath := parsed.EscapedPath()
+	if decoded, err := url.PathUnescape(urlPath); err == nil {
+		urlPath = decoded
+	}
+	urlPath = strings.TrimRight(urlPath, "/")
+	if urlPath == "" {
+		urlPath = "/"
+	}
+
+	return normalizedURL{
+		scheme: scheme,
+		host:   strings.TrimSuffix(strings.ToLower(parsed.Hostname()), "."),
+		port:   port,
+		path:   urlPath,
+	}, nil
+}
+
+func parseURLPattern(exclusion string) (urlPattern, error) {
+	u, err := parseNormalizedURL(exclusion)
+	if err != nil {
+		return urlPattern{}, err
+	}
+	return urlPattern{
+		scheme: u.scheme,
+		host:   u.host,
+		port:   u.port,
+		path:   u.path,
+		glob:   strings.ContainsAny(u.path, "*?["),
+	}, nil
+}
+
+// matches checks if the url is on the same scheme, host and port of the pattern,
+// and its path is the pattern path or is under it
+func (p urlPattern) matches(u normalizedURL) bool {
+	// an empty scheme, in the pattern or in the url, matches any scheme
+	if p.scheme != "" && u.scheme != "" && p.scheme != u.scheme {
+		return false
+	}
+	if p.host != u.host || p.port != u.port {
+		return false
+	}
+	if p.path == "/" {
+		return true
+	}
+
+	if !p.glob {
+		return u.path == p.path || strings.HasPrefix(u.path, p.path+"/")
+	}
+
+	// match the glob against the path, and all its parents
+	for prefix := u.path; prefix != ""; {
+		if matched, err := path.Match(p.path, prefix); err == nil && matched {
+			return true
+		}
+		i := strings.LastIndex(prefix, "/")
+		if i <= 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return false
+}
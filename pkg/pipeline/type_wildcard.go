@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Wildcard is a domain under which any name resolves
+type Wildcard struct {
+	// Domain is the root of the wildcard
+	Domain string `yaml:"domain"`
+	// Depth is the number of labels under Domain from which any name resolves:
+	// 1 for *.example.com, 2 for *.*.example.com
+	Depth int `yaml:"depth"`
+	// IPs are all the addresses the wildcard answered with.
+	// Round-robin wildcards answer with a different subset every time
+	IPs []string `yaml:"ips,omitempty"`
+	// Declared is set when the wildcard comes from the scope instead of being detected
+	Declared bool `yaml:"declared,omitempty"`
+}
+
+// ParseWildcard parses a wildcard declaration, like *.example.com or *.*.example.com
+func ParseWildcard(declaration string) (Wildcard, error) {
+	domain := strings.TrimSpace(declaration)
+	depth := 0
+	for strings.HasPrefix(domain, "*.") {
+		domain = strings.TrimPrefix(domain, "*.")
+		depth++
+	}
+	if depth == 0 || domain == "" || strings.Contains(domain, "*") {
+		return Wildcard{}, fmt.Errorf("invalid wildcard declaration '%s'", declaration)
+	}
+	return Wildcard{Domain: normalize_domain(domain), Depth: depth, Declared: true}, nil
+}
+
+// MergeWildcards merges the declared wildcards with the detected ones.
+// Declarations override detections: a detected wildcard on, or under,
+// a declared wildcard domain is dropped.
+func MergeWildcards(declared []Wildcard, detected []Wildcard) []Wildcard {
+	merged := slices.Clone(declared)
+	for _, wildcard := range detected {
+		overridden := slices.ContainsFunc(declared, func(d Wildcard) bool {
+			return wildcard.Domain == d.Domain || isSubdomain(wildcard.Domain, d.Domain)
+		})
+		if !overridden {
+			merged = append(merged, wildcard)
+		}
+	}
+	slices.SortFunc(merged, func(a, b Wildcard) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return merged
+}
+
+// String returns the wildcard in the *.example.com notation
+func (w Wildcard) String() string {
+	return strings.Repeat("*.", w.Depth) + w.Domain
+}
+
+// Covers checks if domain is under the wildcard, and resolves
+// regardless of whether it exists
+func (w Wildcard) Covers(domain string) bool {
+	return w.coversBelow(domain, 0)
+}
+
+// CoversChildren checks if the children of domain are under the wildcard
+func (w Wildcard) CoversChildren(domain string) bool {
+	return w.coversBelow(domain, 1)
+}
+
+// coversBelow checks if the names that are extra labels below domain are under the wildcard
+func (w Wildcard) coversBelow(domain string, extra int) bool {
+	domain = normalize_domain(domain)
+	root := normalize_domain(w.Domain)
+	labels := extra
+	if domain != root {
+		if !isSubdomain(domain, root) {
+			return false
+		}
+		labels += countDots(strings.TrimSuffix(domain, "."+root)) + 1
+	}
+	return labels >= w.Depth
+}
+
+// WildcardDomains returns the root domains of the wildcards
+func WildcardDomains(wildcards []Wildcard) []string {
+	domains := make([]string, 0, len(wildcards))
+	for _, w := range wildcards {
+		domains = append(domains, w.Domain)
+	}
+	return domains
+}
+
+// randomName returns a name that is depth random labels under domain
+func randomName(domain string, depth int) string {
+	labels := make([]string, 0, depth+1)
+	for range depth {
+		labels = append(labels, strings.ReplaceAll(uuid.New().String(), "-", ""))
+	}
+	return strings.Join(append(labels, domain), ".")
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWildcardCovers(t *testing.T) {
+	single := Wildcard{Domain: "example.com", Depth: 1}
+	multi := Wildcard{Domain: "example.com", Depth: 2}
+
+	tests := []struct {
+		name             string
+		wildcard         Wildcard
+		domain           string
+		expectedCovers   bool
+		expectedChildren bool
+	}{
+		{"root of a single-level wildcard", single, "example.com", false, true},
+		{"child of a single-level wildcard", single, "dev.example.com", true, true},
+		{"grandchild of a single-level wildcard", single, "a.dev.example.com", true, true},
+		{"root of a multi-level wildcard", multi, "example.com", false, false},
+		{"child of a multi-level wildcard", multi, "dev.example.com", false, true},
+		{"grandchild of a multi-level wildcard", multi, "a.dev.example.com", true, true},
+		{"case and trailing dot", single, "Dev.Example.com.", true, true},
+		{"other domain", single, "example.org", false, false},
+		{"suffix that is not a parent", single, "notexample.com", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.wildcard.Covers(tt.domain); got != tt.expectedCovers {
+				t.Errorf("Covers(%s) = %v, want %v", tt.domain, got, tt.expectedCovers)
+			}
+			if got := tt.wildcard.CoversChildren(tt.domain); got != tt.expectedChildren {
+				t.Errorf("CoversChildren(%s) = %v, want %v", tt.domain, got, tt.expectedChildren)
+			}
+		})
+	}
+}
+
+func TestWildcardString(t *testing.T) {
+	if got := (Wildcard{Domain: "example.com", Depth: 2}).String(); got != "*.*.example.com" {
+		t.Errorf("String() = %s, want *.*.example.com", got)
+	}
+}
+
+func TestParseWildcard(t *testing.T) {
+	tests := []struct {
+		declaration string
+		expected    Wildcard
+		expectErr   bool
+	}{
+		{"*.preview.example.com", Wildcard{Domain: "preview.example.com", Depth: 1, Declared: true}, false},
+		{"*.*.Apps.Example.com", Wildcard{Domain: "apps.example.com", Depth: 2, Declared: true}, false},
+		{"preview.example.com", Wildcard{}, true},
+		{"*.preview.*.example.com", Wildcard{}, true},
+		{"*.", Wildcard{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.declaration, func(t *testing.T) {
+			got, err := ParseWildcard(tt.declaration)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseWildcard() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeWildcards(t *testing.T) {
+	declared := []Wildcard{{Domain: "preview.example.com", Depth: 2, Declared: true}}
+	detected := []Wildcard{
+		// the declaration wins over what was detected on the same domain
+		{Domain: "preview.example.com", Depth: 1, IPs: []string{"192.0.2.1"}},
+		{Domain: "a.preview.example.com", Depth: 1, IPs: []string{"192.0.2.1"}},
+		{Domain: "apps.example.com", Depth: 1, IPs: []string{"192.0.2.2"}},
+	}
+
+	got := MergeWildcards(declared, detected)
+	expected := []Wildcard{
+		{Domain: "apps.example.com", Depth: 1, IPs: []string{"192.0.2.2"}},
+		{Domain: "preview.example.com", Depth: 2, Declared: true},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("MergeWildcards() = %+v, want %+v", got, expected)
+	}
+}
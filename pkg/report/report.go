@@ -0,0 +1,255 @@
+// Package report renders the overview of a run: a human-readable recap of the
+// changes in the surface and of the detected issues, with the line to copy into
+// ignore-issues.yaml for each issue.
+// The overview is the file reviewers read in the merge requests of the data folder.
+// It is rendered as Markdown for them, and as YAML for the tools that read the result of a run.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+	"github.com/robalb/tinyasm/pkg/pipeline"
+	"github.com/robalb/tinyasm/pkg/surfacediff"
+	"gopkg.in/yaml.v3"
+)
+
+// Overview is everything the report of a run is rendered from
+type Overview struct {
+	Surface surfacediff.Result
+	Issues  issues.Result
+	// Time is when the run happened
+	Time time.Time
+}
+
+// Markdown renders the overview as a Markdown document
+func (o *Overview) Markdown() []byte {
+	var b bytes.Buffer
+	// writes to a bytes.Buffer never fail
+	_ = o.WriteMarkdown(&b)
+	return b.Bytes()
+}
+
+// WriteMarkdown writes the overview to w, as a Markdown document
+func (o *Overview) WriteMarkdown(w io.Writer) error {
+	m := &markdown{w: w}
+
+	m.line("# Surface overview")
+	m.line("")
+	m.line("Run of %s.", o.Time.UTC().Format(time.RFC1123))
+	m.line("")
+	o.writeSummary(m)
+
+	m.line("")
+	m.line("## New surface")
+	m.line("")
+	o.writeSurface(m, func(c surfacediff.Changes) []string { return c.Added })
+
+	m.line("")
+	m.line("## Gone surface")
+	m.line("")
+	o.writeSurface(m, func(c surfacediff.Changes) []string { return c.Removed })
+
+	if len(o.Surface.HTTP) > 0 {
+		m.line("")
+		m.line("## Changed surface")
+		m.line("")
+		for _, change := range o.Surface.HTTP {
+			m.line("- `%s`: %s", change.URL, strings.Join(change.Changes, ", "))
+		}
+	}
+
+	if len(o.Surface.Wildcards) > 0 {
+		m.line("")
+		m.line("## Wildcards")
+		m.line("")
+		for _, wildcard := range o.Surface.Wildcards {
+			origin := "detected"
+			if wildcard.Declared {
+				origin = "declared"
+			}
+			line := fmt.Sprintf("- `%s` (%s)", wildcard, origin)
+			if len(wildcard.IPs) > 0 {
+				line += ": " + strings.Join(wildcard.IPs, ", ")
+			}
+			m.line("%s", line)
+		}
+	}
+
+	m.line("")
+	m.line("## Issues detected")
+	m.line("")
+	writeIssues(m, o.Issues.New, false)
+
+	m.line("")
+	m.line("## Old issues")
+	m.line("")
+	writeIssues(m, o.Issues.Old, true)
+
+	if len(o.Issues.Resolved) > 0 {
+		m.line("")
+		m.line("## Resolved issues")
+		m.line("")
+		for _, issue := range o.Issues.Resolved {
+			m.line("- `%s`: %s", issue.Asset, issue.Description)
+		}
+	}
+
+	if len(o.Issues.Ignored) > 0 {
+		m.line("")
+		m.line("%d issues were detected, but are listed in ignore-issues.yaml.", len(o.Issues.Ignored))
+	}
+	return m.err
+}
+
+func (o *Overview) writeSummary(m *markdown) {
+	m.line("| | new | gone | unchanged |")
+	m.line("|---|---|---|---|")
+	for _, row := range []struct {
+		kind    string
+		changes surfacediff.Changes
+	}{
+		{"domains", o.Surface.Domains},
+		{"ips", o.Surface.IPs},
+		{"urls", o.Surface.URLs},
+		{"services", o.Surface.Services},
+	} {
+		m.line("| %s | %d | %d | %d |", row.kind, len(row.changes.Added), len(row.changes.Removed), len(row.changes.Unchanged))
+	}
+	m.line("")
+	m.line("Issues: %d new, %d old, %d resolved, %d ignored.",
+		len(o.Issues.New), len(o.Issues.Old), len(o.Issues.Resolved), len(o.Issues.Ignored))
+}
+
+func (o *Overview) writeSurface(m *markdown, pick func(surfacediff.Changes) []string) {
+	empty := true
+	for _, list := range []struct {
+		kind    string
+		changes surfacediff.Changes
+	}{
+		{"domain", o.Surface.Domains},
+		{"ip", o.Surface.IPs},
+		{"url", o.Surface.URLs},
+		{"service", o.Surface.Services},
+	} {
+		for _, v := range pick(list.changes) {
+			empty = false
+			m.line("- %s: `%s`", list.kind, v)
+		}
+	}
+	if empty {
+		m.line("None.")
+	}
+}
+
+// writeIssues writes a list of issues, each followed by the line
+// that suppresses it, ready to be copied into ignore-issues.yaml
+func writeIssues(m *markdown, list []issues.Issue, firstSeen bool) {
+	if len(list) == 0 {
+		m.line("None.")
+		return
+	}
+	for _, issue := range list {
+		line := fmt.Sprintf("- **%s** on `%s`: %s", issue.Kind, issue.Asset, issue.Description)
+		if firstSeen {
+			line += fmt.Sprintf(" (first seen %s)", issue.FirstSeen.UTC().Format(time.DateOnly))
+		}
+		m.line("%s", line)
+		m.line("  If false positive, add this line to ignore-issues.yaml:")
+		m.line("  ```yaml")
+		m.line("  %s", issue.IgnoreLine())
+		m.line("  ```")
+	}
+}
+
+// yamlOverview is the YAML form of the overview.
+// Like in the Markdown one, unchanged elements are only counted
+type yamlOverview struct {
+	Time      time.Time                `yaml:"time"`
+	New       yamlSurface              `yaml:"new_surface"`
+	Gone      yamlSurface              `yaml:"gone_surface"`
+	Unchanged yamlCounts               `yaml:"unchanged_surface"`
+	Changed   []surfacediff.HTTPChange `yaml:"changed_surface"`
+	Wildcards []pipeline.Wildcard      `yaml:"wildcards"`
+	Issues    yamlIssues               `yaml:"issues"`
+}
+
+type yamlSurface struct {
+	Domains  []string `yaml:"domains"`
+	IPs      []string `yaml:"ips"`
+	URLs     []string `yaml:"urls"`
+	Services []string `yaml:"services"`
+}
+
+type yamlCounts struct {
+	Domains  int `yaml:"domains"`
+	IPs      int `yaml:"ips"`
+	URLs     int `yaml:"urls"`
+	Services int `yaml:"services"`
+}
+
+type yamlIssues struct {
+	// New and Old are the issues detected in this run. Their id is
+	// the line to add to ignore-issues.yaml to suppress them
+	New      []issues.Issue `yaml:"detected"`
+	Old      []issues.Issue `yaml:"old"`
+	Resolved []issues.Issue `yaml:"resolved"`
+	Ignored  int            `yaml:"ignored"`
+}
+
+// YAML renders the overview as a YAML document
+func (o *Overview) YAML() ([]byte, error) {
+	pick := func(list func(surfacediff.Changes) []string) yamlSurface {
+		return yamlSurface{
+			Domains:  list(o.Surface.Domains),
+			IPs:      list(o.Surface.IPs),
+			URLs:     list(o.Surface.URLs),
+			Services: list(o.Surface.Services),
+		}
+	}
+	return yaml.Marshal(yamlOverview{
+		Time: o.Time.UTC(),
+		New:  pick(func(c surfacediff.Changes) []string { return orEmpty(c.Added) }),
+		Gone: pick(func(c surfacediff.Changes) []string { return orEmpty(c.Removed) }),
+		Unchanged: yamlCounts{
+			Domains:  len(o.Surface.Domains.Unchanged),
+			IPs:      len(o.Surface.IPs.Unchanged),
+			URLs:     len(o.Surface.URLs.Unchanged),
+			Services: len(o.Surface.Services.Unchanged),
+		},
+		Changed:   orEmpty(o.Surface.HTTP),
+		Wildcards: orEmpty(o.Surface.Wildcards),
+		Issues: yamlIssues{
+			New:      orEmpty(o.Issues.New),
+			Old:      orEmpty(o.Issues.Old),
+			Resolved: orEmpty(o.Issues.Resolved),
+			Ignored:  len(o.Issues.Ignored),
+		},
+	})
+}
+
+// orEmpty returns an empty slice instead of nil, so that empty lists
+// are written as [] instead of null
+func orEmpty[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
+
+// markdown writes lines to w, and remembers the first error
+type markdown struct {
+	w   io.Writer
+	err error
+}
+
+func (m *markdown) line(format string, args ...any) {
+	if m.err != nil {
+		return
+	}
+	_, m.err = fmt.Fprintf(m.w, format+"\n", args...)
+}
@@ -0,0 +1,145 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/issues"
+	"github.com/robalb/tinyasm/pkg/pipeline"
+	"github.com/robalb/tinyasm/pkg/surfacediff"
+	"gopkg.in/yaml.v3"
+)
+
+func testOverview() Overview {
+	firstSeen := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	surface := surfacediff.Diff(
+		pipeline.Surface{Domains: []string{"old.example.com", "www.example.com"}},
+		pipeline.Surface{Domains: []string{"new.example.com", "www.example.com"}, URLs: []string{"https://new.example.com/"}},
+	)
+	surface.HTTP = []surfacediff.HTTPChange{{URL: "https://www.example.com/", Changes: []string{"status 200 -> 500"}}}
+	surface.Wildcards = []pipeline.Wildcard{{Domain: "preview.example.com", Depth: 1, Declared: true}}
+
+	takeover := issues.New("subdomain-takeover", "docs.example.com", "github-pages", "unclaimed GitHub Pages site")
+	dangling := issues.New("dangling-cname", "old.example.com", "", "CNAME to a name that does not exist")
+	dangling.FirstSeen = firstSeen
+	return Overview{
+		Surface: surface,
+		Issues: issues.Result{
+			New:      []issues.Issue{takeover},
+			Old:      []issues.Issue{dangling},
+			Resolved: []issues.Issue{issues.New("dangling-cname", "gone.example.com", "", "CNAME to a name that does not exist")},
+			Ignored:  []issues.Issue{issues.New("dangling-cname", "ignored.example.com", "", "")},
+		},
+		Time: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	overview := testOverview()
+	md := string(overview.Markdown())
+
+	expected := []string{
+		"# Surface overview",
+		"Run of Wed, 04 Mar 2026 05:06:07 UTC.",
+		"| domains | 1 | 1 | 1 |",
+		"| urls | 1 | 0 | 0 |",
+		"Issues: 1 new, 1 old, 1 resolved, 1 ignored.",
+		"## New surface\n\n- domain: `new.example.com`\n- url: `https://new.example.com/`\n",
+		"## Gone surface\n\n- domain: `old.example.com`\n",
+		"## Changed surface\n\n- `https://www.example.com/`: status 200 -> 500\n",
+		"## Wildcards\n\n- `*.preview.example.com` (declared)\n",
+		"## Issues detected\n\n- **subdomain-takeover** on `docs.example.com`: unclaimed GitHub Pages site\n",
+		"  ```yaml\n  - \"subdomain-takeover:github-pages:docs.example.com\"\n  ```\n",
+		"on `old.example.com`: CNAME to a name that does not exist (first seen 2026-01-02)",
+		"## Resolved issues\n\n- `gone.example.com`",
+		"1 issues were detected, but are listed in ignore-issues.yaml.",
+	}
+	for _, e := range expected {
+		if !strings.Contains(md, e) {
+			t.Errorf("Overview doesn't contain %q:\n%s", e, md)
+		}
+	}
+	// the ignored issues are only counted
+	if strings.Contains(md, "ignored.example.com") {
+		t.Errorf("Overview lists an ignored issue:\n%s", md)
+	}
+}
+
+func TestMarkdownEmpty(t *testing.T) {
+	overview := Overview{Surface: surfacediff.Diff(pipeline.Surface{}, pipeline.Surface{}), Time: time.Now()}
+	md := string(overview.Markdown())
+
+	for _, section := range []string{"## New surface\n\nNone.", "## Gone surface\n\nNone.", "## Issues detected\n\nNone.", "## Old issues\n\nNone."} {
+		if !strings.Contains(md, section) {
+			t.Errorf("Overview doesn't contain %q:\n%s", section, md)
+		}
+	}
+	for _, section := range []string{"## Changed surface", "## Wildcards", "## Resolved issues", "ignore-issues.yaml"} {
+		if strings.Contains(md, section) {
+			t.Errorf("Overview contains the empty section %q:\n%s", section, md)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriteMarkdownError(t *testing.T) {
+	overview := testOverview()
+	if err := overview.WriteMarkdown(failingWriter{}); err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	overview := testOverview()
+	data, err := overview.YAML()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got yamlOverview
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Failed to parse the overview: %v\n%s", err, data)
+	}
+
+	if !got.Time.Equal(overview.Time) {
+		t.Errorf("Expected time %v, got %v", overview.Time, got.Time)
+	}
+	if !reflect.DeepEqual(got.New.Domains, []string{"new.example.com"}) || !reflect.DeepEqual(got.New.URLs, []string{"https://new.example.com/"}) {
+		t.Errorf("Unexpected new surface: %+v", got.New)
+	}
+	if !reflect.DeepEqual(got.Gone.Domains, []string{"old.example.com"}) {
+		t.Errorf("Unexpected gone surface: %+v", got.Gone)
+	}
+	if got.Unchanged != (yamlCounts{Domains: 1}) {
+		t.Errorf("Unexpected unchanged surface: %+v", got.Unchanged)
+	}
+	if !reflect.DeepEqual(got.Changed, overview.Surface.HTTP) || !reflect.DeepEqual(got.Wildcards, overview.Surface.Wildcards) {
+		t.Errorf("Unexpected changed surface or wildcards: %+v, %+v", got.Changed, got.Wildcards)
+	}
+	if len(got.Issues.New) != 1 || got.Issues.New[0].ID != "subdomain-takeover:github-pages:docs.example.com" {
+		t.Errorf("Unexpected detected issues: %+v", got.Issues.New)
+	}
+	if len(got.Issues.Old) != 1 || len(got.Issues.Resolved) != 1 || got.Issues.Ignored != 1 {
+		t.Errorf("Unexpected issues: %+v", got.Issues)
+	}
+	// the ignored issues are only counted
+	if strings.Contains(string(data), "ignored.example.com") {
+		t.Errorf("Overview lists an ignored issue:\n%s", data)
+	}
+}
+
+func TestYAMLEmpty(t *testing.T) {
+	overview := Overview{Surface: surfacediff.Diff(pipeline.Surface{}, pipeline.Surface{}), Time: time.Now()}
+	data, err := overview.YAML()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), "null") {
+		t.Errorf("Expected empty lists, got:\n%s", data)
+	}
+}
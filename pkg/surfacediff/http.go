@@ -0,0 +1,88 @@
+package surfacediff
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+// HTTPChange lists what changed in the http response of a single URL
+type HTTPChange struct {
+	URL     string   `yaml:"url"`
+	Changes []string `yaml:"changes"`
+}
+
+// DiffHTTP compares the http responses of a previous run with the ones of the current run.
+// Only URLs that responded in both runs are compared. Body hash, content length and
+// response time are not compared: they change on most dynamic pages, and would only be noise.
+func DiffHTTP(previous, current map[string]pipeline.HTTPInfo) []HTTPChange {
+	result := []HTTPChange{}
+	for url, cur := range current {
+		prev, exists := previous[url]
+		if !exists {
+			continue
+		}
+		if changes := diffHTTPInfo(prev, cur); len(changes) > 0 {
+			result = append(result, HTTPChange{URL: url, Changes: changes})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
+
+func diffHTTPInfo(prev, cur pipeline.HTTPInfo) []string {
+	changes := []string{}
+	if prev.StatusCode != cur.StatusCode {
+		changes = append(changes, fmt.Sprintf("status %d -> %d", prev.StatusCode, cur.StatusCode))
+	}
+	if prev.Title != cur.Title {
+		changes = append(changes, fmt.Sprintf("title %q -> %q", prev.Title, cur.Title))
+	}
+	if prev.Server != cur.Server {
+		changes = append(changes, fmt.Sprintf("server %q -> %q", prev.Server, cur.Server))
+	}
+
+	tech := diffStrings(prev.Technologies, cur.Technologies)
+	if len(tech.Added) > 0 {
+		changes = append(changes, "new technologies: "+strings.Join(tech.Added, ", "))
+	}
+	if len(tech.Removed) > 0 {
+		changes = append(changes, "gone technologies: "+strings.Join(tech.Removed, ", "))
+	}
+
+	if !slices.Equal(prev.Redirects, cur.Redirects) {
+		changes = append(changes, fmt.Sprintf("redirects %v -> %v", prev.Redirects, cur.Redirects))
+	}
+	if prev.CDN != cur.CDN || prev.CDNName != cur.CDNName {
+		changes = append(changes, fmt.Sprintf("cdn %q -> %q", cdnName(prev), cdnName(cur)))
+	}
+
+	switch {
+	case prev.TLS == nil && cur.TLS != nil:
+		changes = append(changes, fmt.Sprintf("new tls certificate for %q", cur.TLS.Subject))
+	case prev.TLS != nil && cur.TLS == nil:
+		changes = append(changes, "tls certificate gone")
+	case prev.TLS != nil && cur.TLS != nil:
+		// a renewed certificate only changes its expiry, and is not worth a report
+		if prev.TLS.Subject != cur.TLS.Subject || prev.TLS.Issuer != cur.TLS.Issuer || !slices.Equal(prev.TLS.SANs, cur.TLS.SANs) {
+			changes = append(changes, fmt.Sprintf("tls certificate %q by %q -> %q by %q",
+				prev.TLS.Subject, prev.TLS.Issuer, cur.TLS.Subject, cur.TLS.Issuer))
+		}
+	}
+	return changes
+}
+
+func cdnName(info pipeline.HTTPInfo) string {
+	if !info.CDN {
+		return "none"
+	}
+	if info.CDNName == "" {
+		return "unknown"
+	}
+	return info.CDNName
+}
@@ -0,0 +1,101 @@
+package surfacediff
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func TestDiffHTTP(t *testing.T) {
+	expiry := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := pipeline.HTTPInfo{
+		StatusCode:    200,
+		Title:         "Welcome",
+		ContentLength: 1200,
+		BodyHash:      "aaaa",
+		Server:        "nginx",
+		Technologies:  []string{"Nginx"},
+		TLS:           &pipeline.TLSInfo{Subject: "example.com", SANs: []string{"example.com"}, Issuer: "R3", NotAfter: expiry},
+		ResponseTime:  120 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name     string
+		previous pipeline.HTTPInfo
+		current  func(pipeline.HTTPInfo) pipeline.HTTPInfo
+		expected []string
+	}{
+		{
+			name:     "noisy fields are ignored",
+			previous: base,
+			current: func(i pipeline.HTTPInfo) pipeline.HTTPInfo {
+				i.BodyHash = "bbbb"
+				i.ContentLength = 1300
+				i.ResponseTime = 300 * time.Millisecond
+				i.TLS = &pipeline.TLSInfo{Subject: "example.com", SANs: []string{"example.com"}, Issuer: "R3", NotAfter: expiry.AddDate(0, 3, 0)}
+				return i
+			},
+			expected: nil,
+		},
+		{
+			name:     "new technology",
+			previous: base,
+			current: func(i pipeline.HTTPInfo) pipeline.HTTPInfo {
+				i.Title = "Dashboard [Jenkins]"
+				i.Server = "Jetty(10.0.13)"
+				i.Technologies = []string{"Java", "Jenkins"}
+				return i
+			},
+			expected: []string{
+				`title "Welcome" -> "Dashboard [Jenkins]"`,
+				`server "nginx" -> "Jetty(10.0.13)"`,
+				"new technologies: Java, Jenkins",
+				"gone technologies: Nginx",
+			},
+		},
+		{
+			name:     "status, redirects, cdn and certificate",
+			previous: base,
+			current: func(i pipeline.HTTPInfo) pipeline.HTTPInfo {
+				i.StatusCode = 302
+				i.Redirects = []string{"https://example.com/login"}
+				i.CDN = true
+				i.CDNName = "cloudflare"
+				i.TLS = &pipeline.TLSInfo{Subject: "example.com", Issuer: "Cloudflare", NotAfter: expiry}
+				return i
+			},
+			expected: []string{
+				"status 200 -> 302",
+				"redirects [] -> [https://example.com/login]",
+				`cdn "none" -> "cloudflare"`,
+				`tls certificate "example.com" by "R3" -> "example.com" by "Cloudflare"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := "https://example.com"
+			previous := map[string]pipeline.HTTPInfo{url: tt.previous}
+			current := map[string]pipeline.HTTPInfo{
+				url: tt.current(tt.previous),
+				// urls that did not respond before are new surface, not changed surface
+				"https://new.example.com": base,
+			}
+
+			got := DiffHTTP(previous, current)
+			var expected []HTTPChange
+			if tt.expected != nil {
+				expected = []HTTPChange{{URL: url, Changes: tt.expected}}
+			}
+			if len(got) == 0 && len(expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("DiffHTTP() = %+v, want %+v", got, expected)
+			}
+		})
+	}
+}
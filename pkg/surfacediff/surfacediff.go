@@ -0,0 +1,120 @@
+// Package surfacediff compares two surfaces, and classifies every element
+// as new, gone or unchanged. It also reports the changes in the http responses
+// of the URLs seen in both.
+package surfacediff
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+// Changes is the classification of a single list of surface elements.
+// All the lists are sorted and never contain duplicates.
+type Changes struct {
+	Added     []string `yaml:"added"`
+	Removed   []string `yaml:"removed"`
+	Unchanged []string `yaml:"unchanged"`
+}
+
+// Result is the classification of every element of two surfaces
+type Result struct {
+	Domains  Changes `yaml:"domains"`
+	IPs      Changes `yaml:"ips"`
+	URLs     Changes `yaml:"urls"`
+	Services Changes `yaml:"services"`
+	// HTTP is not set by Diff, see DiffHTTP
+	HTTP []HTTPChange `yaml:"http"`
+	// Wildcards are not set by Diff. They are reported as they are,
+	// since the children of a wildcard are only discovered over http
+	Wildcards []pipeline.Wildcard `yaml:"wildcards"`
+}
+
+// Diff compares the surface of a previous run with the surface of the current run.
+// Elements only in current are Added, elements only in previous are Removed,
+// and elements in both are Unchanged.
+func Diff(previous, current pipeline.Surface) Result {
+	return Result{
+		Domains:  diffStrings(previous.Domains, current.Domains),
+		IPs:      diffStrings(previous.IPs, current.IPs),
+		URLs:     diffStrings(previous.URLs, current.URLs),
+		Services: diffStrings(previous.Services, current.Services),
+	}
+}
+
+func diffStrings(previous, current []string) Changes {
+	previousSet := make(map[string]struct{}, len(previous))
+	for _, v := range previous {
+		previousSet[v] = struct{}{}
+	}
+	currentSet := make(map[string]struct{}, len(current))
+	for _, v := range current {
+		currentSet[v] = struct{}{}
+	}
+
+	c := Changes{
+		Added:     []string{},
+		Removed:   []string{},
+		Unchanged: []string{},
+	}
+	for v := range currentSet {
+		if _, exists := previousSet[v]; exists {
+			c.Unchanged = append(c.Unchanged, v)
+		} else {
+			c.Added = append(c.Added, v)
+		}
+	}
+	for v := range previousSet {
+		if _, exists := currentSet[v]; !exists {
+			c.Removed = append(c.Removed, v)
+		}
+	}
+
+	slices.Sort(c.Added)
+	slices.Sort(c.Removed)
+	slices.Sort(c.Unchanged)
+	return c
+}
+
+// HasChanges reports whether any element was added, removed or changed
+func (r *Result) HasChanges() bool {
+	if len(r.HTTP) > 0 {
+		return true
+	}
+	for _, c := range r.all() {
+		if len(c.changes.Added) > 0 || len(c.changes.Removed) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Result) Summary() string {
+	return fmt.Sprintf(
+		"New surface: {Domains[%d], IPs[%d], Endpoints[%d], Services[%d]}, Gone surface: {Domains[%d], IPs[%d], Endpoints[%d], Services[%d]}, Changed endpoints: %d",
+		len(r.Domains.Added),
+		len(r.IPs.Added),
+		len(r.URLs.Added),
+		len(r.Services.Added),
+		len(r.Domains.Removed),
+		len(r.IPs.Removed),
+		len(r.URLs.Removed),
+		len(r.Services.Removed),
+		len(r.HTTP),
+	)
+}
+
+type kindChanges struct {
+	kind    string
+	changes Changes
+}
+
+func (r *Result) all() []kindChanges {
+	return []kindChanges{
+		{"domain", r.Domains},
+		{"ip", r.IPs},
+		{"url", r.URLs},
+		{"service", r.Services},
+	}
+}
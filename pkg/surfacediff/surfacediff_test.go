@@ -0,0 +1,126 @@
+package surfacediff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/robalb/tinyasm/pkg/pipeline"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous pipeline.Surface
+		current  pipeline.Surface
+		expected Result
+	}{
+		{
+			name:     "empty surfaces",
+			previous: pipeline.Surface{},
+			current:  pipeline.Surface{},
+			expected: Result{
+				Domains:  Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+				IPs:      Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+				URLs:     Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+				Services: Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+			},
+		},
+		{
+			name:     "first run",
+			previous: pipeline.Surface{},
+			current: pipeline.Surface{
+				Domains: []string{"b.example.com", "a.example.com"},
+				IPs:     []string{"10.0.0.1"},
+			},
+			expected: Result{
+				Domains:  Changes{Added: []string{"a.example.com", "b.example.com"}, Removed: []string{}, Unchanged: []string{}},
+				IPs:      Changes{Added: []string{"10.0.0.1"}, Removed: []string{}, Unchanged: []string{}},
+				URLs:     Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+				Services: Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+			},
+		},
+		{
+			name: "new, gone and unchanged",
+			previous: pipeline.Surface{
+				Domains:  []string{"a.example.com", "old.example.com"},
+				URLs:     []string{"https://example.com", "https://old.example.com"},
+				Services: []string{"example.com:8443"},
+			},
+			current: pipeline.Surface{
+				Domains:  []string{"a.example.com", "new.example.com", "a.example.com"},
+				URLs:     []string{"https://example.com"},
+				Services: []string{"example.com:8443", "example.com:8080"},
+			},
+			expected: Result{
+				Domains:  Changes{Added: []string{"new.example.com"}, Removed: []string{"old.example.com"}, Unchanged: []string{"a.example.com"}},
+				IPs:      Changes{Added: []string{}, Removed: []string{}, Unchanged: []string{}},
+				URLs:     Changes{Added: []string{}, Removed: []string{"https://old.example.com"}, Unchanged: []string{"https://example.com"}},
+				Services: Changes{Added: []string{"example.com:8080"}, Removed: []string{}, Unchanged: []string{"example.com:8443"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.previous, tt.current)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	same := pipeline.Surface{Domains: []string{"a.example.com"}, URLs: []string{"https://ci.example.com"}}
+	tests := []struct {
+		name     string
+		result   func() Result
+		expected bool
+	}{
+		{
+			name: "new and gone domains",
+			result: func() Result {
+				return Diff(
+					pipeline.Surface{Domains: []string{"a.example.com", "old.example.com"}},
+					pipeline.Surface{Domains: []string{"a.example.com", "new.example.com"}},
+				)
+			},
+			expected: true,
+		},
+		{
+			name:     "identical surfaces",
+			result:   func() Result { return Diff(same, same) },
+			expected: false,
+		},
+		{
+			name: "wildcards are not changes",
+			result: func() Result {
+				r := Diff(same, same)
+				r.Wildcards = []pipeline.Wildcard{{Domain: "example.com", Depth: 1, IPs: []string{"192.0.2.1"}}}
+				return r
+			},
+			expected: false,
+		},
+		{
+			name: "changed http response",
+			result: func() Result {
+				r := Diff(same, same)
+				r.HTTP = DiffHTTP(
+					map[string]pipeline.HTTPInfo{"https://ci.example.com": {StatusCode: 404}},
+					map[string]pipeline.HTTPInfo{"https://ci.example.com": {StatusCode: 200}},
+				)
+				return r
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.result()
+			if got := r.HasChanges(); got != tt.expected {
+				t.Errorf("HasChanges() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
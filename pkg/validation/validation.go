@@ -5,7 +5,10 @@ import (
 	"net"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/robalb/tinyasm/pkg/iprange"
 )
 
 func ValidateDomain(domain string) error {
@@ -34,31 +37,40 @@ func ValidateDomain(domain string) error {
 	return nil
 }
 
-func ValidateIP(ip string) error {
-	if ip == "" {
-		return fmt.Errorf("IP cannot be empty")
+// ValidateDomainPattern validates a domain exclusion, which can be
+// prefixed by = to exclude only the exact domain, and can contain
+// * wildcards in place of, or as part of, its labels
+func ValidateDomainPattern(pattern string) error {
+	domain := strings.TrimPrefix(strings.TrimSpace(pattern), "=")
+	if domain == "" {
+		return fmt.Errorf("Domain cannot be empty")
 	}
 
-	// Remove leading/trailing whitespace
-	ip = strings.TrimSpace(ip)
-
-	// Check if it's a CIDR notation
-	if strings.Contains(ip, "/") {
-		_, _, err := net.ParseCIDR(ip)
-		if err != nil {
-			return fmt.Errorf("invalid CIDR notation '%s'", ip)
+	// every wildcard must be within a single label
+	for _, label := range strings.Split(domain, ".") {
+		if strings.Contains(label, "**") {
+			return fmt.Errorf("domain pattern '%s' has invalid format", pattern)
 		}
-		return nil
 	}
 
-	// Check if it's a valid IP address
-	if net.ParseIP(ip) == nil {
-		return fmt.Errorf("invalid IP address '%s'", ip)
+	// once the wildcards are replaced, the pattern must be a valid domain
+	if err := ValidateDomain(strings.ReplaceAll(domain, "*", "x")); err != nil {
+		return fmt.Errorf("domain pattern '%s' has invalid format", pattern)
 	}
 
 	return nil
 }
 
+func ValidateIP(ip string) error {
+	if ip == "" {
+		return fmt.Errorf("IP cannot be empty")
+	}
+
+	// IPs, CIDRs and ranges are parsed like the pipeline will
+	_, err := iprange.Parse(ip)
+	return err
+}
+
 func ValidateURL(endpoint string) error {
 	if endpoint == "" {
 		return fmt.Errorf("endpoint cannot be empty")
@@ -85,3 +97,78 @@ func ValidateURL(endpoint string) error {
 
 	return nil
 }
+
+func ValidatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d is out of the range 1-65535", port)
+	}
+	return nil
+}
+
+// ValidateService validates a network service in the format host:port,
+// where host is a domain or an IP address. IPv6 addresses must be in brackets
+func ValidateService(service string) error {
+	if service == "" {
+		return fmt.Errorf("service cannot be empty")
+	}
+
+	host, port, err := net.SplitHostPort(strings.TrimSpace(service))
+	if err != nil {
+		return fmt.Errorf("service '%s' must be in the format host:port", service)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("service '%s' has an invalid port", service)
+	}
+	if err := ValidatePort(portNumber); err != nil {
+		return fmt.Errorf("service '%s' has an invalid port: %w", service, err)
+	}
+
+	if net.ParseIP(host) == nil {
+		if err := ValidateDomain(host); err != nil {
+			return fmt.Errorf("service '%s' has an invalid host: %w", service, err)
+		}
+	}
+
+	return nil
+}
+
+func ValidateIssueID(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("issue identifier cannot be empty")
+	}
+
+	// Issue identifiers are in the format kind[:detail]:asset
+	kind, asset, found := strings.Cut(id, ":")
+	if !found || kind == "" || asset == "" {
+		return fmt.Errorf("issue identifier '%s' has invalid format. Copy the identifier from the issues report", id)
+	}
+
+	return nil
+}
+
+// ValidateWildcard validates a wildcard declaration: a domain prefixed by
+// one *. label for every level of the wildcard, like *.example.com or *.*.example.com
+func ValidateWildcard(wildcard string) error {
+	if wildcard == "" {
+		return fmt.Errorf("wildcard cannot be empty")
+	}
+
+	domain := strings.TrimSpace(wildcard)
+	if !strings.HasPrefix(domain, "*.") {
+		return fmt.Errorf("wildcard '%s' must start with *.", wildcard)
+	}
+	for strings.HasPrefix(domain, "*.") {
+		domain = strings.TrimPrefix(domain, "*.")
+	}
+
+	if strings.Contains(domain, "*") {
+		return fmt.Errorf("wildcard '%s' can only have * labels at the start", wildcard)
+	}
+	if err := ValidateDomain(domain); err != nil {
+		return fmt.Errorf("wildcard '%s' has an invalid domain: %w", wildcard, err)
+	}
+
+	return nil
+}
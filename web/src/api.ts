@@ -0,0 +1,118 @@
+import type { Definition, OperatorInfo } from './nodes'
+
+/** The outcome of the validation of a pipeline by the editor backend */
+export interface ValidationResult {
+  valid: boolean
+  error?: string
+  order?: string[]
+}
+
+export async function fetchOperators(): Promise<OperatorInfo[]> {
+  const res = await fetch('/api/operators')
+  if (!res.ok) throw new Error(`failed to load the operators: ${res.status}`)
+  return res.json()
+}
+
+/** Loads the pipeline asm would run: the one in the config folder, or the built-in one */
+export async function fetchPipeline(): Promise<Definition> {
+  const res = await fetch('/api/pipeline')
+  if (!res.ok) throw new Error(await res.text())
+  return res.json()
+}
+
+export async function validatePipeline(definition: Definition): Promise<ValidationResult> {
+  return sendPipeline('POST', '/api/pipeline/validate', definition)
+}
+
+/** Saves the pipeline into the config folder, where asm reads it. Invalid pipelines are rejected */
+export async function savePipeline(definition: Definition): Promise<ValidationResult> {
+  return sendPipeline('PUT', '/api/pipeline', definition)
+}
+
+async function sendPipeline(method: string, url: string, definition: Definition): Promise<ValidationResult> {
+  const res = await fetch(url, {
+    method,
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(definition)
+  })
+  if (res.headers.get('Content-Type')?.includes('application/json')) {
+    return res.json()
+  }
+  return { valid: false, error: await res.text() }
+}
+
+/** A value read or written by a node during a debug run */
+export interface ValueInfo {
+  count: number
+  value: unknown
+}
+
+/** Sent by the backend when a node of a debug run finishes */
+export interface NodeEvent {
+  id: string
+  operator: string
+  inputs: Record<string, ValueInfo>
+  outputs: Record<string, ValueInfo>
+  duration_ms: number
+  error?: string
+}
+
+export type DebugEvent =
+  | { type: 'start'; order: string[] }
+  | { type: 'node'; node: NodeEvent }
+  | { type: 'done' }
+  | { type: 'error'; error: string }
+
+/**
+ * Runs a pipeline in the backend debug mode, calling onEvent as the nodes finish.
+ * Aborting signal cancels the run in the backend.
+ */
+export async function runDebug(
+  definition: Definition,
+  signal: AbortSignal,
+  onEvent: (event: DebugEvent) => void
+): Promise<void> {
+  const res = await fetch('/api/debug/run', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(definition),
+    signal
+  })
+  if (!res.ok || !res.body) {
+    const text = await res.text()
+    let error = text
+    try {
+      error = (JSON.parse(text) as ValidationResult).error ?? text
+    } catch {
+      // plain text error
+    }
+    onEvent({ type: 'error', error })
+    return
+  }
+
+  // server-sent events, parsed from the response stream:
+  // EventSource can't send the pipeline in the body of a POST
+  const reader = res.body.pipeThrough(new TextDecoderStream()).getReader()
+  let buffer = ''
+  for (;;) {
+    const { value, done } = await reader.read()
+    if (done) return
+    buffer += value
+    let end: number
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const raw = buffer.slice(0, end)
+      buffer = buffer.slice(end + 2)
+      let name = ''
+      let data = ''
+      for (const line of raw.split('\n')) {
+        if (line.startsWith('event: ')) name = line.slice('event: '.length)
+        else if (line.startsWith('data: ')) data = line.slice('data: '.length)
+      }
+      const payload = JSON.parse(data || '{}')
+      if (name === 'start') onEvent({ type: 'start', order: payload.order })
+      else if (name === 'node') onEvent({ type: 'node', node: payload })
+      else if (name === 'done') onEvent({ type: 'done' })
+      else if (name === 'error') onEvent({ type: 'error', error: payload.error })
+    }
+  }
+}
@@ -0,0 +1,58 @@
+import { ClassicPreset, type NodeEditor } from 'rete'
+import type { AreaPlugin } from 'rete-area-plugin'
+
+import { OperatorNode, type Definition, type OperatorInfo, type Schemes } from './nodes'
+
+/** Exports the graph in the editor as a pipeline definition */
+export function exportDefinition<A>(
+  editor: NodeEditor<Schemes>,
+  area: AreaPlugin<Schemes, A>,
+  name: string
+): Definition {
+  const nodes = editor.getNodes().map((n) => {
+    const view = area.nodeViews.get(n.id)
+    return {
+      id: n.nodeId,
+      operator: n.operator.name,
+      params: n.params(),
+      position: view ? { x: view.position.x, y: view.position.y } : undefined
+    }
+  })
+  const edges = editor.getConnections().map((c) => {
+    const source = editor.getNode(c.source)
+    const target = editor.getNode(c.target)
+    return { from: `${source.nodeId}.${c.sourceOutput}`, to: `${target.nodeId}.${c.targetInput}` }
+  })
+  return { name, nodes, edges }
+}
+
+/** Replaces the graph in the editor with the one of a pipeline definition */
+export async function importDefinition<A>(
+  editor: NodeEditor<Schemes>,
+  area: AreaPlugin<Schemes, A>,
+  definition: Definition,
+  operators: OperatorInfo[]
+): Promise<void> {
+  await editor.clear()
+
+  const byId = new Map<string, OperatorNode>()
+  for (const [i, n] of definition.nodes.entries()) {
+    const operator = operators.find((o) => o.name === n.operator)
+    if (!operator) throw new Error(`unknown operator '${n.operator}' in node ${n.id}`)
+    const node = new OperatorNode(operator, n.id, n.params ?? {})
+    byId.set(n.id, node)
+    await editor.addNode(node)
+    // definitions written by hand have no positions: lay them out in a grid
+    const position = n.position ?? { x: (i % 5) * 320, y: Math.floor(i / 5) * 280 }
+    await area.translate(node.id, position)
+  }
+
+  for (const e of definition.edges ?? []) {
+    const [fromId, output] = e.from.split('.')
+    const [toId, input] = e.to.split('.')
+    const source = byId.get(fromId)
+    const target = byId.get(toId)
+    if (!source || !target) throw new Error(`edge ${e.from} -> ${e.to} connects an unknown node`)
+    await editor.addConnection(new ClassicPreset.Connection(source, output, target, input))
+  }
+}
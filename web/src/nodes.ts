@@ -1,100 +1,123 @@
 import { ClassicPreset, type GetSchemes } from 'rete'
 
-/**
- * A single data socket for simplicity. You can split this
- * into stricter socket types (Domain vs List) and add connection
- * validation via editor pipes later if you want.
- */
-export const dataSocket = new ClassicPreset.Socket('data')
+/** The description of an operator, as served by the editor backend at /api/operators */
+export interface SocketInfo {
+  name: string
+  type: string
+  description: string
+  optional?: boolean
+}
 
-/** Join: merges two string lists (deduped) */
-export class JoinNode extends ClassicPreset.Node {
-  constructor() {
-    super('Join')
-    this.addInput('a', new ClassicPreset.Input(dataSocket, 'List A'))
-    this.addInput('b', new ClassicPreset.Input(dataSocket, 'List B'))
-    this.addOutput('list', new ClassicPreset.Output(dataSocket, 'Merged'))
-  }
+export interface ConfigFieldInfo {
+  name: string
+  type: 'string' | 'int' | 'bool'
+  description: string
+  default?: unknown
+  values?: string[]
+}
 
-  // inputs.a => [listA], inputs.b => [listB]
-  data(inputs: { a?: string[][]; b?: string[][] }): { list: string[] } {
-    const a = (inputs.a?.[0] ?? []) as string[]
-    const b = (inputs.b?.[0] ?? []) as string[]
-    const merged = Array.from(new Set([...a, ...b]))
-    return { list: merged }
-  }
+export interface OperatorInfo {
+  name: string
+  description: string
+  inputs: SocketInfo[]
+  outputs: SocketInfo[]
+  config: ConfigFieldInfo[]
 }
 
-/** Find Subdomains: Domain -> [subdomains...] (demo stub) */
-export class FindSubdomainsNode extends ClassicPreset.Node {
-  private domainText = 'example.com'
+/** A pipeline definition, in the format asm reads from pipeline.yaml */
+export interface NodeDefinition {
+  id: string
+  operator: string
+  params?: Record<string, unknown>
+  position?: { x: number; y: number }
+}
 
-  constructor() {
-    super('Find Subdomains')
-    this.addInput('domain', new ClassicPreset.Input(dataSocket, 'Domain'))
-    this.addOutput('list', new ClassicPreset.Output(dataSocket, 'Subdomains'))
-    this.addControl(
-      'domain',
-      new ClassicPreset.InputControl('text', {
-        initial: this.domainText,
-        change: (v) => (this.domainText = String(v ?? ''))
-      })
-    )
-  }
+export interface Edge {
+  from: string
+  to: string
+}
 
-  data(inputs: { domain?: unknown[] }): { list: string[] } {
-    const incoming = inputs.domain?.[0]
-    const fallback = this.domainText.trim()
-    const root = (typeof incoming === 'string' ? incoming : fallback).replace(/^https?:\/\//, '')
-    if (!root) return { list: [] }
-    // Deterministic demo data so you can wire graphs
-    const out = [`www.${root}`, `api.${root}`, `dev.${root}`, `staging.${root}`]
-    return { list: out }
-  }
+export interface Definition {
+  name?: string
+  nodes: NodeDefinition[]
+  edges: Edge[]
 }
 
-/** Find Wildcard Domains: Domain -> [wildcards...] (demo stub) */
-export class FindWildcardDomainsNode extends ClassicPreset.Node {
-  private domainText = 'example.com'
+/**
+ * One socket per data type. Two sockets can only be connected
+ * when they have the same name, like the backend enforces.
+ */
+const sockets = new Map<string, ClassicPreset.Socket>()
+
+export function socketFor(type: string): ClassicPreset.Socket {
+  let socket = sockets.get(type)
+  if (!socket) {
+    socket = new ClassicPreset.Socket(type)
+    sockets.set(type, socket)
+  }
+  return socket
+}
 
-  constructor() {
-    super('Find Wildcard Domains')
-    this.addInput('domain', new ClassicPreset.Input(dataSocket, 'Domain'))
-    this.addOutput('list', new ClassicPreset.Output(dataSocket, 'Wildcards'))
-    this.addControl(
-      'domain',
-      new ClassicPreset.InputControl('text', {
-        initial: this.domainText,
-        change: (v) => (this.domainText = String(v ?? ''))
-      })
-    )
+/** A node running one of the registered operators */
+export class OperatorNode extends ClassicPreset.Node {
+  constructor(
+    public readonly operator: OperatorInfo,
+    public readonly nodeId: string,
+    params: Record<string, unknown> = {}
+  ) {
+    super(`${nodeId} · ${operator.name}`)
+    for (const s of operator.inputs) {
+      const label = `${s.name} (${s.type}${s.optional ? ', optional' : ''})`
+      this.addInput(s.name, new ClassicPreset.Input(socketFor(s.type), label))
+    }
+    for (const s of operator.outputs) {
+      this.addOutput(s.name, new ClassicPreset.Output(socketFor(s.type), `${s.name} (${s.type})`))
+    }
+    for (const field of operator.config) {
+      const initial = params[field.name] ?? field.default
+      if (field.type === 'int') {
+        this.addControl(
+          field.name,
+          new ClassicPreset.InputControl('number', { initial: typeof initial === 'number' ? initial : undefined })
+        )
+      } else {
+        this.addControl(
+          field.name,
+          new ClassicPreset.InputControl('text', { initial: initial === undefined ? '' : String(initial) })
+        )
+      }
+    }
   }
 
-  data(inputs: { domain?: unknown[] }): { list: string[] } {
-    const incoming = inputs.domain?.[0]
-    const fallback = this.domainText.trim()
-    const root = (typeof incoming === 'string' ? incoming : fallback).replace(/^https?:\/\//, '')
-    if (!root) return { list: [] }
-    // Deterministic demo patterns
-    const out = [`*.${root}`, `*.dev.${root}`, `*.staging.${root}`]
-    return { list: out }
+  /** The parameters set in the controls. Empty controls are left out */
+  params(): Record<string, unknown> {
+    const params: Record<string, unknown> = {}
+    for (const field of this.operator.config) {
+      const control = this.controls[field.name] as ClassicPreset.InputControl<'text' | 'number'> | undefined
+      const value = control?.value
+      if (value === undefined || value === '') continue
+      if (field.type === 'int') params[field.name] = Number(value)
+      else if (field.type === 'bool') params[field.name] = value === 'true'
+      else params[field.name] = value
+    }
+    return params
   }
 }
 
-export type Node = JoinNode | FindSubdomainsNode | FindWildcardDomainsNode
+export type Node = OperatorNode
 export type Conn = ClassicPreset.Connection<Node, Node>
 export type Schemes = GetSchemes<Node, Conn>
 
-/** Factory used by the palette */
-export function createNode(kind: 'join' | 'find-subdomains' | 'find-wildcard-domains'): Node {
-  switch (kind) {
-    case 'join':
-      return new JoinNode()
-    case 'find-subdomains':
-      return new FindSubdomainsNode()
-    case 'find-wildcard-domains':
-      return new FindWildcardDomainsNode()
-    default:
-      throw new Error(`Unknown node kind: ${kind}`)
+/** Returns a node id that is not used by any of the nodes */
+export function uniqueNodeId(operator: string, nodes: Node[]): string {
+  const used = new Set(nodes.map((n) => n.nodeId))
+  for (let i = 1; ; i++) {
+    const id = `${operator}_${i}`
+    if (!used.has(id)) return id
   }
 }
+
+/** Factory used by the palette */
+export function createNode(operator: OperatorInfo, nodes: Node[]): Node {
+  return new OperatorNode(operator, uniqueNodeId(operator.name, nodes))
+}